@@ -1,92 +1,150 @@
 package main
 
 import (
+	"context"
+	"log"
+	"log/slog"
+	"net"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
 	"time"
 
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
 	"github.com/Harrison-Dev/go_feed_tool/internal/handler"
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/openapi"
+	"github.com/Harrison-Dev/go_feed_tool/internal/tracing"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	r := gin.Default()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("設定錯誤: %v", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format))
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("設定錯誤: %v", err)
+	}
+	if err := handler.Configure(cfg); err != nil {
+		log.Fatalf("設定錯誤: %v", err)
+	}
 
-	r.GET("/health", func(c *gin.Context) {
-		c.String(http.StatusOK, "ok")
-	})
+	// gin 的預設 logger 換成含 request ID 的結構化存取紀錄
+	r := gin.New()
+	r.Use(gin.Recovery(), tracingMiddleware(), requestLogMiddleware(), metricsMiddleware())
 
-	// Plurk 路由
-	r.GET("/plurk/search", func(c *gin.Context) {
-		keyword := c.Query("keyword")
-		rss, err := handler.ProcessPlurkSearch(keyword)
-		if err != nil {
-			c.String(500, err.Error())
-			return
-		}
-		c.String(200, rss)
-	})
+	// 伺服器專用路由；feed 與 /ready 路由和 Cloud Functions 共用同一份定義 (handler.Routes)
+	// 管理 API (handler.AdminRoutes) 也只在伺服器上提供
+	routes := append(serverRoutes(cfg), handler.Routes...)
+	routes = append(routes, handler.AdminRoutes...)
+	routes = append(routes, openapi.Routes(routes)...)
 
-	r.GET("/plurk/top", func(c *gin.Context) {
-		qType := c.Query("qType")
-		rss, err := handler.ProcessPlurkTop(qType)
-		if err != nil {
-			c.String(500, err.Error())
-			return
-		}
-		c.String(200, rss)
-	})
+	// 每個路由依 OpenAPI 規格驗證參數，錯誤時回結構化的 400；
+	// feed 路由接受簽章網址，設定 auth.keys_file 時否則需要 API key；
+	// 管理 API 需要 auth.admin_token
+	for _, route := range routes {
+		r.Handle(route.HTTPMethod(), ginPath(route.Path), gin.WrapF(handler.Mount(route)))
+	}
 
-	// PTT 路由
-	r.GET("/ptt/search", func(c *gin.Context) {
-		parser := handler.NewPttParser(&http.Client{Timeout: 15 * time.Second})
-		keyword := c.Query("keyword")
-		board := c.Query("board")
-		page := 1
-		if p, err := strconv.Atoi(c.DefaultQuery("page", "1")); err == nil {
-			page = p
-		}
-		pages := 1
-		if p, err := strconv.Atoi(c.DefaultQuery("pages", "1")); err == nil {
-			pages = p
-		}
-		rss, err := parser.FetchArticlesPaged(board, keyword, page, pages)
-		if err != nil {
-			c.String(500, err.Error())
-			return
-		}
-		c.Header("Content-Type", "application/rss+xml; charset=utf-8")
-		c.String(200, rss)
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// PTT 熱門文章 (已爆文 + AI 預測潛在爆文)
-	// GET /ptt/trending?board=C_Chat&threshold=0.5&limit=20&mode=all
-	// mode: "viral" (已爆文), "potential" (潛在爆文), "all" (兩者都要, 預設)
-	r.GET("/ptt/trending", func(c *gin.Context) {
-		parser := handler.NewPttParser(http.DefaultClient)
-		board := c.DefaultQuery("board", "C_Chat")
-		threshold := c.DefaultQuery("threshold", "0.5")
-		limit := c.DefaultQuery("limit", "20")
-		mode := c.DefaultQuery("mode", "all")
+	ln, err := net.Listen("tcp", cfg.Server.Addr)
+	if err != nil {
+		log.Fatalf("監聽失敗: %v", err)
+	}
+	if err := serve(ctx, newHTTPServer(cfg.Server, r), ln, cfg.Server.ShutdownDelay, cfg.Server.ShutdownTimeout, shutdownTracing); err != nil {
+		slog.Error("服務結束時發生錯誤", "err", err)
+		os.Exit(1)
+	}
+}
 
-		thresholdFloat := 0.5
-		if t, err := strconv.ParseFloat(threshold, 64); err == nil {
-			thresholdFloat = t
-		}
+// ginPath converts the {name} path parameters of a route to gin's :name.
+func ginPath(path string) string {
+	return pathParam.ReplaceAllString(path, ":$1")
+}
 
-		limitInt := 20
-		if l, err := strconv.Atoi(limit); err == nil {
-			limitInt = l
-		}
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// serverRoutes are the endpoints that only make sense on the long-running
+// server, not on Cloud Functions.
+func serverRoutes(cfg *config.Config) []handler.Route {
+	return []handler.Route{
+		{
+			Path:        "/health",
+			Summary:     "存活檢查",
+			ContentType: "text/plain",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+		},
+		{
+			Path:        "/metrics",
+			Summary:     "Prometheus 指標",
+			ContentType: "text/plain",
+			Handler:     metrics.Handler().ServeHTTP,
+		},
+		{
+			// 目前生效的設定 (敏感值已遮蔽)；仍含檔案路徑與已儲存的 feed，需要管理 token
+			Path:        "/debug/config",
+			Summary:     "目前生效的設定",
+			ContentType: "text/plain",
+			Admin:       true,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				out, err := cfg.Redacted().YAML()
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.Write(out)
+			},
+		},
+	}
+}
 
-		rss, err := parser.FetchTrendingArticles(board, thresholdFloat, limitInt, mode)
-		if err != nil {
-			c.String(500, err.Error())
-			return
+// tracingMiddleware starts the server span of each request, continuing the
+// caller's trace when a traceparent header is present.
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
 		}
-		c.Header("Content-Type", "application/rss+xml; charset=utf-8")
-		c.String(200, rss)
-	})
+		req, span := tracing.StartRequest(c.Request, route)
+		c.Request = req
+		c.Next()
+		tracing.EndRequest(span, c.Writer.Status())
+	}
+}
 
-	r.Run(":8080")
+// requestLogMiddleware tags the request with an ID (see logging.Begin) and
+// writes one access log line when it completes.
+func requestLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Request = logging.Begin(c.Writer, c.Request)
+		c.Next()
+		logging.LogRequest(c.Request, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// metricsMiddleware records every request under its route pattern, so
+// /ptt/search?board=A and ?board=B share one series.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(route, c.Request.Method, c.Writer.Status(), time.Since(start))
+	}
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/Harrison-Dev/go_feed_tool/internal/handler"
+)
+
+// newHTTPServer applies the server timeouts from cfg to h.
+func newHTTPServer(cfg config.ServerConfig, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// cleanupTimeout bounds each cleanup, which runs after the drain deadline
+// may already have passed.
+const cleanupTimeout = 5 * time.Second
+
+// serve runs srv on ln until ctx is canceled (SIGTERM/SIGINT), then marks
+// the service as draining and keeps serving for delay, so the load balancer
+// sees /ready fail and stops sending new requests before the listener
+// closes. It then stops accepting connections and waits for in-flight
+// requests until drain after the signal; requests still running at the
+// deadline are cut off. cleanups (flushing traces, stopping background
+// work) run last, each with its own cleanupTimeout.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, delay, drain time.Duration, cleanups ...func(context.Context) error) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	slog.Info("服務啟動", "addr", ln.Addr().String())
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("收到結束訊號，等待進行中的請求完成", "delay", delay, "timeout", drain)
+	handler.BeginShutdown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	select {
+	case <-time.After(delay):
+	case <-shutdownCtx.Done():
+	}
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("等待逾時，強制關閉剩餘連線", "err", err)
+		errs = append(errs, err, srv.Close())
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	for _, cleanup := range cleanups {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		errs = append(errs, cleanup(cleanupCtx))
+		cancel()
+	}
+	slog.Info("服務已停止")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		work        time.Duration
+		drain       time.Duration
+		wantErr     bool
+		wantRequest bool
+	}{
+		{name: "request finishes within deadline", work: 200 * time.Millisecond, drain: 2 * time.Second, wantRequest: true},
+		{name: "request cut off at deadline", work: 2 * time.Second, drain: 100 * time.Millisecond, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tt.work):
+					w.Write([]byte("feed"))
+				case <-r.Context().Done():
+				}
+			})}
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cleaned := false
+			served := make(chan error, 1)
+			go func() {
+				served <- serve(ctx, srv, ln, 0, tt.drain, func(ctx context.Context) error {
+					// 排空逾時後仍有自己的期限送出 trace
+					cleaned = ctx.Err() == nil
+					return nil
+				})
+			}()
+
+			body := make(chan string, 1)
+			go func() {
+				resp, err := http.Get("http://" + ln.Addr().String() + "/ptt/trending")
+				if err != nil {
+					body <- ""
+					return
+				}
+				defer resp.Body.Close()
+				b, _ := io.ReadAll(resp.Body)
+				body <- string(b)
+			}()
+
+			<-started
+			cancel() // SIGTERM while the feed is being built
+
+			err = <-served
+			if (err != nil) != tt.wantErr {
+				t.Errorf("serve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := <-body; (got == "feed") != tt.wantRequest {
+				t.Errorf("in-flight response = %q, want completed=%v", got, tt.wantRequest)
+			}
+			if !cleaned {
+				t.Error("cleanup was not run or got an expired context")
+			}
+			if _, err := net.DialTimeout("tcp", ln.Addr().String(), 100*time.Millisecond); err == nil {
+				t.Error("listener still accepts connections after shutdown")
+			}
+		})
+	}
+}
+
+func TestServeKeepsListeningDuringDelay(t *testing.T) {
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, srv, ln, 300*time.Millisecond, 2*time.Second) }()
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	// 負載平衡器看到 /ready 失敗前仍送來的請求照常處理
+	resp, err := http.Get("http://" + ln.Addr().String() + "/ready")
+	if err != nil {
+		t.Fatalf("request during shutdown delay: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve did not stop after the delay")
+	}
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), 100*time.Millisecond); err == nil {
+		t.Error("listener still accepts connections after shutdown")
+	}
+}
@@ -0,0 +1,54 @@
+// Command signurl mints a signed feed URL that can be subscribed to without
+// an API key, using the first secret of auth.signing_secrets.
+//
+//	go run ./cmd/signurl -config config.yaml -ttl 720h -base https://feed.example.com '/ptt/trending?board=C_Chat'
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/Harrison-Dev/go_feed_tool/internal/handler"
+)
+
+func main() {
+	fs := flag.NewFlagSet("signurl", flag.ExitOnError)
+	file := fs.String("config", os.Getenv("FEED_TOOL_CONFIG"), "path to YAML config file")
+	ttl := fs.Duration("ttl", 0, "validity of the URL, e.g. 720h; 0 never expires")
+	base := fs.String("base", "", "public base URL of the service, e.g. https://feed.example.com")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: signurl [flags] '/ptt/trending?board=C_Chat'")
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load([]string{"-config", *file})
+	if err != nil {
+		log.Fatalf("設定錯誤: %v", err)
+	}
+	if err := handler.Configure(cfg); err != nil {
+		log.Fatalf("設定錯誤: %v", err)
+	}
+	signed, err := handler.SignFeedURL(fs.Arg(0), *ttl)
+	if err != nil {
+		log.Fatalf("簽章失敗: %v", err)
+	}
+	// 傳入完整網址時簽出的也是完整網址，不再加上 -base
+	if u, err := url.Parse(signed.URL); err == nil && !u.IsAbs() {
+		signed.URL = strings.TrimSuffix(*base, "/") + signed.URL
+	}
+	fmt.Println(signed.URL)
+	if signed.ExpiresAt != nil {
+		fmt.Fprintf(os.Stderr, "有效期限至 %s\n", signed.ExpiresAt.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,272 @@
+// Package auth checks API keys on feed requests and enforces each key's rate
+// limit and daily quota. Keys live in a YAML file that is re-read when it
+// changes, so keys can be added or revoked without a restart.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// Header carries the API key; feed readers that cannot set headers use the
+// key query parameter instead.
+const Header = "X-API-Key"
+
+// minKeyLength rejects guessable keys in the key file.
+const minKeyLength = 16
+
+// taipei is where the daily quota resets at midnight.
+var taipei = time.FixedZone("CST", 8*60*60)
+
+// Key is one entry of the key file.
+type Key struct {
+	Name       string `yaml:"name"`        // 出現在日誌與指標中，不可重複
+	Key        string `yaml:"key"`         // 至少 16 字元
+	RateLimit  int    `yaml:"rate_limit"`  // 每分鐘請求數，0 表示不限
+	DailyQuota int    `yaml:"daily_quota"` // 每日請求數 (台北時間 0 點重置)，0 表示不限
+	Disabled   bool   `yaml:"disabled"`
+}
+
+type keyFile struct {
+	Keys []Key `yaml:"keys"`
+}
+
+// keyState is a key with its usage. It survives reloads as long as the key
+// value stays in the file, so editing the file does not reset limits.
+type keyState struct {
+	Key
+	bucket *ratelimit.Bucket
+	day    string // quota day (YYYY-MM-DD, Taipei) of used
+	used   int
+}
+
+// Store holds the keys of one key file.
+type Store struct {
+	path     string
+	interval time.Duration // minimum time between checks of the file
+	now      func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]*keyState // by key value
+	modTime time.Time
+	size    int64
+	checked time.Time
+	lastErr error // last failed reload; the previous keys stay in use
+}
+
+// Load reads the key file at path. The file is checked for changes at most
+// once per interval, on the next request after the interval has passed.
+// When the file cannot be loaded, Load returns the error together with an
+// empty store that rejects every key until the file is fixed.
+func Load(path string, interval time.Duration) (*Store, error) {
+	s := &Store{path: path, interval: interval, now: time.Now, keys: make(map[string]*keyState)}
+	if err := s.reload(); err != nil {
+		s.lastErr = err
+		return s, err
+	}
+	return s, nil
+}
+
+// Status reports the number of active keys and the error of the last
+// reload, if it failed.
+func (s *Store) Status() (keys int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if !k.Disabled {
+			keys++
+		}
+	}
+	return keys, s.lastErr
+}
+
+// reloadIfChanged re-reads the key file when its size or modification time
+// changed. Must be called with s.mu held.
+func (s *Store) reloadIfChanged(now time.Time) {
+	if now.Sub(s.checked) < s.interval {
+		return
+	}
+	s.checked = now
+	info, err := os.Stat(s.path)
+	if err != nil {
+		s.lastErr = err
+		slog.Warn("API key 檔讀取失敗，沿用目前的 key", "path", s.path, "err", err)
+		return
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return
+	}
+	if err := s.reload(); err != nil {
+		s.lastErr = err
+		slog.Warn("API key 檔載入失敗，沿用目前的 key", "path", s.path, "err", err)
+		return
+	}
+	slog.Info("API key 檔已重新載入", "path", s.path, "keys", len(s.keys))
+}
+
+// reload parses the key file and swaps in its keys, carrying over the usage
+// of keys that are still present.
+func (s *Store) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var file keyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	if err := validate(file.Keys); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+
+	keys := make(map[string]*keyState, len(file.Keys))
+	for _, k := range file.Keys {
+		state := &keyState{Key: k}
+		if old, ok := s.keys[k.Key]; ok {
+			state.day, state.used = old.day, old.used
+			if old.RateLimit == k.RateLimit {
+				state.bucket = old.bucket
+			}
+		}
+		if state.bucket == nil && k.RateLimit > 0 {
+			state.bucket = ratelimit.NewBucket(k.RateLimit, k.RateLimit)
+		}
+		keys[k.Key] = state
+	}
+	s.keys = keys
+	s.modTime, s.size = info.ModTime(), info.Size()
+	s.lastErr = nil
+	return nil
+}
+
+func validate(keys []Key) error {
+	var errs []error
+	names := make(map[string]bool)
+	values := make(map[string]bool)
+	for i, k := range keys {
+		switch {
+		case k.Name == "":
+			errs = append(errs, fmt.Errorf("keys[%d].name must not be empty", i))
+		case names[k.Name]:
+			errs = append(errs, fmt.Errorf("keys[%d].name %q is duplicated", i, k.Name))
+		}
+		names[k.Name] = true
+		switch {
+		case len(k.Key) < minKeyLength:
+			errs = append(errs, fmt.Errorf("keys[%d].key must be at least %d characters", i, minKeyLength))
+		case values[k.Key]:
+			errs = append(errs, fmt.Errorf("keys[%d].key is duplicated", i))
+		}
+		values[k.Key] = true
+		if k.RateLimit < 0 || k.DailyQuota < 0 {
+			errs = append(errs, fmt.Errorf("keys[%d] rate_limit and daily_quota must not be negative", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Decision is the outcome of checking one request.
+type Decision struct {
+	Status     int    // 200 when allowed, otherwise 401 or 429
+	Name       string // key name, "-" when the key is missing or unknown
+	Result     string // ok, missing, invalid, rate_limited or quota_exceeded
+	RetryAfter time.Duration
+	Remaining  int // requests left today, -1 when the key has no quota
+}
+
+// Check authenticates key and charges one request to it.
+func (s *Store) Check(key string) Decision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.reloadIfChanged(now)
+	if key == "" {
+		return Decision{Status: http.StatusUnauthorized, Name: "-", Result: "missing", Remaining: -1}
+	}
+	k, ok := s.keys[key]
+	if !ok || k.Disabled {
+		return Decision{Status: http.StatusUnauthorized, Name: "-", Result: "invalid", Remaining: -1}
+	}
+
+	d := Decision{Status: http.StatusOK, Name: k.Name, Result: "ok", Remaining: -1}
+	day := now.In(taipei).Format(time.DateOnly)
+	if k.day != day {
+		k.day, k.used = day, 0
+	}
+	if k.DailyQuota > 0 && k.used >= k.DailyQuota {
+		y, m, dd := now.In(taipei).Date()
+		d.Status, d.Result, d.Remaining = http.StatusTooManyRequests, "quota_exceeded", 0
+		d.RetryAfter = time.Date(y, m, dd+1, 0, 0, 0, 0, taipei).Sub(now)
+		return d
+	}
+	if k.bucket != nil {
+		if ok, wait := k.bucket.Take(now, 1); !ok {
+			d.Status, d.Result, d.RetryAfter = http.StatusTooManyRequests, "rate_limited", wait
+			return d
+		}
+	}
+	k.used++
+	if k.DailyQuota > 0 {
+		d.Remaining = k.DailyQuota - k.used
+	}
+	return d
+}
+
+// FromRequest returns the API key of r: the X-API-Key header, or the key
+// query parameter.
+func FromRequest(r *http.Request) string {
+	if key := r.Header.Get(Header); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("key")
+}
+
+// Middleware rejects requests without a valid key (401) or over the key's
+// rate limit or quota (429 with Retry-After). Allowed requests are logged
+// with the key name.
+func (s *Store) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := s.Check(FromRequest(r))
+		metrics.APIKeyRequests.WithLabelValues(d.Name, d.Result).Inc()
+		if d.Remaining >= 0 {
+			w.Header().Set("X-Quota-Remaining", strconv.Itoa(d.Remaining))
+		}
+
+		switch d.Status {
+		case http.StatusOK:
+			logger := logging.FromRequest(r).With("api_key", d.Name)
+			next(w, r.WithContext(logging.WithLogger(r.Context(), logger)))
+			return
+		case http.StatusUnauthorized:
+			w.Header().Set("WWW-Authenticate", `ApiKey header="`+Header+`", query="key"`)
+			writeError(w, d.Status, d.Result+" API key")
+		default:
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+			writeError(w, d.Status, d.Result)
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testKeys = `
+keys:
+  - name: reader
+    key: reader-key-0123456789
+    rate_limit: 2
+  - name: quota
+    key: quota-key-0123456789
+    daily_quota: 2
+  - name: revoked
+    key: revoked-key-0123456789
+    disabled: true
+`
+
+func writeKeys(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 2026-01-22 23:59:30 台北時間，第三個請求時已跨日
+var testStart = time.Date(2026, 1, 22, 23, 59, 30, 0, taipei)
+
+func TestMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeKeys(t, path, testKeys, testStart)
+
+	tests := []struct {
+		name       string
+		header     string
+		query      string
+		at         []time.Duration // request times after testStart; the last one is checked
+		status     int
+		retryAfter string
+		quotaLeft  string
+	}{
+		{name: "missing key", at: []time.Duration{0}, status: 401},
+		{name: "unknown key", query: "key=guess-0123456789abc", at: []time.Duration{0}, status: 401},
+		{name: "disabled key", query: "key=revoked-key-0123456789", at: []time.Duration{0}, status: 401},
+		{name: "header key", header: "reader-key-0123456789", at: []time.Duration{0}, status: 200},
+		{name: "query key", query: "key=reader-key-0123456789", at: []time.Duration{0}, status: 200},
+		{
+			name:       "rate limited",
+			query:      "key=reader-key-0123456789",
+			at:         []time.Duration{0, 0, 0},
+			status:     429,
+			retryAfter: "30",
+		},
+		{
+			name:       "quota exceeded until midnight",
+			query:      "key=quota-key-0123456789",
+			at:         []time.Duration{0, 0, 0},
+			status:     429,
+			retryAfter: "30",
+			quotaLeft:  "0",
+		},
+		{
+			name:      "quota resets at midnight",
+			query:     "key=quota-key-0123456789",
+			at:        []time.Duration{0, 0, time.Minute},
+			status:    200,
+			quotaLeft: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := Load(path, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var now time.Time
+			store.now = func() time.Time { return now }
+
+			var w *httptest.ResponseRecorder
+			for _, offset := range tt.at {
+				now = testStart.Add(offset)
+				req := httptest.NewRequest("GET", "/ptt/trending?"+tt.query, nil)
+				if tt.header != "" {
+					req.Header.Set(Header, tt.header)
+				}
+				w = httptest.NewRecorder()
+				store.Middleware(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("feed"))
+				})(w, req)
+			}
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if got := w.Header().Get("X-Quota-Remaining"); got != tt.quotaLeft {
+				t.Errorf("X-Quota-Remaining = %q, want %q", got, tt.quotaLeft)
+			}
+		})
+	}
+}
+
+func TestHotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeKeys(t, path, testKeys, testStart)
+	store, err := Load(path, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := testStart
+	store.now = func() time.Time { return now }
+
+	// 用掉一次額度，重新載入後應該保留
+	if d := store.Check("quota-key-0123456789"); d.Remaining != 1 {
+		t.Fatalf("remaining = %d, want 1", d.Remaining)
+	}
+
+	// 撤銷 reader、新增 new-reader
+	writeKeys(t, path, `
+keys:
+  - name: quota
+    key: quota-key-0123456789
+    daily_quota: 2
+  - name: new-reader
+    key: new-reader-key-0123456789
+`, testStart.Add(time.Second))
+
+	if d := store.Check("reader-key-0123456789"); d.Status != 200 {
+		t.Errorf("reader rejected before the reload interval passed: %+v", d)
+	}
+	now = now.Add(10 * time.Second)
+	if d := store.Check("reader-key-0123456789"); d.Status != 401 {
+		t.Errorf("revoked reader = %d, want 401", d.Status)
+	}
+	if d := store.Check("new-reader-key-0123456789"); d.Status != 200 || d.Name != "new-reader" {
+		t.Errorf("new key = %+v, want accepted", d)
+	}
+	if d := store.Check("quota-key-0123456789"); d.Status != 200 || d.Remaining != 0 {
+		t.Errorf("quota after reload = %+v, want usage kept", d)
+	}
+
+	// 壞掉的 key 檔不影響目前的 key
+	writeKeys(t, path, "keys:\n  - name: short\n    key: abc\n", testStart.Add(2*time.Second))
+	now = now.Add(10 * time.Second)
+	if d := store.Check("new-reader-key-0123456789"); d.Status != 200 {
+		t.Errorf("keys dropped after a bad reload: %+v", d)
+	}
+	if n, err := store.Status(); n != 2 || err == nil {
+		t.Errorf("Status() = %d, %v; want 2 keys and the reload error", n, err)
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeKeys(t, path, "keys:\n  - name: a\n    key: short\n  - name: a\n    key: another-key-0123456789\n    rate_limit: -1\n", testStart)
+
+	store, err := Load(path, time.Second)
+	if err == nil {
+		t.Fatal("Load accepted an invalid key file")
+	}
+	if d := store.Check("another-key-0123456789"); d.Status != 401 {
+		t.Errorf("store from a failed load accepted a key: %+v", d)
+	}
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignedURL(t *testing.T) {
+	signer := NewSigner([]string{"current-secret-0123456789"})
+	rotated := NewSigner([]string{"next-secret-0123456789ab", "current-secret-0123456789"})
+	other := NewSigner([]string{"other-secret-0123456789ab"})
+
+	feed, _ := url.Parse("/ptt/trending?board=C_Chat&mode=all&key=reader-key-0123456789")
+	expires := testStart.Add(time.Hour)
+	signed := signer.Sign(feed, expires)
+	forever := signer.Sign(feed, time.Time{})
+
+	if signed.Query().Has("key") {
+		t.Errorf("signed URL leaks the API key: %s", signed)
+	}
+
+	tamper := func(u *url.URL, name, value string) *url.URL {
+		q := u.Query()
+		q.Set(name, value)
+		t := *u
+		t.RawQuery = q.Encode()
+		return &t
+	}
+
+	tests := []struct {
+		name     string
+		signer   *Signer
+		u        *url.URL
+		at       time.Time
+		expected error
+	}{
+		{"valid", signer, signed, testStart, nil},
+		{"no expiry", signer, forever, testStart.Add(24 * 365 * time.Hour), nil},
+		{"tampered parameter", signer, tamper(signed, "board", "Gossiping"), testStart, ErrBadSignature},
+		{"added parameter", signer, tamper(signed, "limit", "100"), testStart, ErrBadSignature},
+		{"extended expiry", signer, tamper(signed, "exp", "9999999999"), testStart, ErrBadSignature},
+		{"other path", signer, &url.URL{Path: "/ptt/search", RawQuery: signed.RawQuery}, testStart, ErrBadSignature},
+		{"expired", signer, signed, expires, ErrExpired},
+		{"old secret still verifies after rotation", rotated, signed, testStart, nil},
+		{"secret removed", other, signed, testStart, ErrBadSignature},
+		{"signing disabled", nil, signed, testStart, ErrNoSecrets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.signer.Verify(tt.u, tt.at); !errors.Is(err, tt.expected) {
+				t.Errorf("Verify(%s) = %v, want %v", tt.u, err, tt.expected)
+			}
+		})
+	}
+}
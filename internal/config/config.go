@@ -0,0 +1,652 @@
+// Package config loads the feed tool settings from a YAML file, environment
+// variables and command line flags, in that order of precedence (flags win).
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/imgproxy"
+	"github.com/Harrison-Dev/go_feed_tool/internal/ratelimit"
+	"github.com/Harrison-Dev/go_feed_tool/internal/savedfeed"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the effective configuration of the feed tool.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Upstream UpstreamConfig `yaml:"upstream"`
+	Predict  PredictConfig  `yaml:"predict"`
+	Trending TrendingConfig `yaml:"trending"`
+	Debug    DebugConfig    `yaml:"debug"`
+	Log      LogConfig      `yaml:"log"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+	Ready    ReadyConfig    `yaml:"ready"`
+	Auth     AuthConfig     `yaml:"auth"`
+
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
+	ImageProxy ImageProxyConfig `yaml:"image_proxy"`
+	SavedFeeds SavedFeedsConfig `yaml:"saved_feeds"`
+
+	// Boards overrides the trending defaults per board, keyed by board name.
+	Boards map[string]BoardProfile `yaml:"boards"`
+}
+
+// ServerConfig controls the HTTP listener.
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"` // 需大於最慢的 feed (trending 往回翻多頁) 產生時間
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"` // 收到 SIGTERM 後等待進行中請求的上限
+	ShutdownDelay     time.Duration `yaml:"shutdown_delay"`   // 收到 SIGTERM 後 /ready 先回 503 多久才停止接受連線，計入 shutdown_timeout
+}
+
+// UpstreamConfig controls requests made to ptt.cc and plurk.com.
+type UpstreamConfig struct {
+	Timeout         time.Duration `yaml:"timeout"`
+	UserAgent       string        `yaml:"user_agent"`
+	ArticleCacheTTL time.Duration `yaml:"article_cache_ttl"` // 文章頁快取時間，0 表示不快取
+	PttBaseURL      string        `yaml:"ptt_base_url"`      // 實際發送 PTT 請求的位址，feed 連結仍為 www.ptt.cc
+	PlurkBaseURL    string        `yaml:"plurk_base_url"`    // 實際發送 Plurk 請求的位址
+}
+
+// PredictConfig points at the Python prediction service.
+type PredictConfig struct {
+	URL        string        `yaml:"url"`
+	TimeWindow int           `yaml:"time_window"` // minutes; must match the loaded model
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// TrendingConfig holds the defaults of /ptt/trending.
+type TrendingConfig struct {
+	ViralPushes       int           `yaml:"viral_pushes"`       // 推文數達此值視為已爆文
+	ControversialBoos int           `yaml:"controversial_boos"` // 噓文數達此值視為爭議文
+	PotentialMaxAge   time.Duration `yaml:"potential_max_age"`  // 潛在爆文最多看多久內的文章
+	DefaultThreshold  float64       `yaml:"default_threshold"`
+	DefaultLimit      int           `yaml:"default_limit"`
+	Pages             int           `yaml:"pages"`     // 未指定 since 時看板列表往回抓幾頁
+	MaxPages          int           `yaml:"max_pages"` // 指定 since 時最多往回抓幾頁
+}
+
+// DebugConfig turns on upstream record/replay for reproducing scraper bugs.
+type DebugConfig struct {
+	RecordDir string `yaml:"record_dir"` // 每個請求的上游往來錄製到此目錄下的子目錄
+	ReplayDir string `yaml:"replay_dir"` // 從錄製目錄重播上游回應，不連網
+}
+
+// LogConfig controls the structured logger.
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn, error
+	Format string `yaml:"format"` // text 或 json
+}
+
+// TracingConfig controls OpenTelemetry tracing.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`     // none, stdout 或 otlp
+	Endpoint    string  `yaml:"endpoint"`     // OTLP/HTTP 位址 (host:port)，空白則用 OTEL_EXPORTER_OTLP_ENDPOINT
+	Insecure    bool    `yaml:"insecure"`     // OTLP 不使用 TLS
+	SampleRatio float64 `yaml:"sample_ratio"` // 0~1，新 trace 的取樣比例
+	ServiceName string  `yaml:"service_name"`
+}
+
+// ReadyConfig controls the dependency checks of /ready.
+type ReadyConfig struct {
+	Timeout  time.Duration `yaml:"timeout"`   // 每項檢查的逾時
+	ProbePTT bool          `yaml:"probe_ptt"` // 是否檢查 PTT 可連線 (也可帶管理 token 用 /ready?probe=ptt 單次開啟)
+}
+
+// AuthConfig turns on API key authentication of the feed routes and signed
+// feed URLs.
+type AuthConfig struct {
+	KeysFile       string        `yaml:"keys_file"`       // API key 檔 (YAML)，空白則不需要 key
+	ReloadInterval time.Duration `yaml:"reload_interval"` // 多久檢查一次 key 檔是否有更新
+	SigningSecrets []string      `yaml:"signing_secrets"` // 簽章網址的密鑰，第一個用來簽，全部都可驗證；移除即撤銷
+	AdminToken     string        `yaml:"admin_token"`     // /admin 路由的 Bearer token，空白則停用
+}
+
+// RateLimitConfig limits each client IP on the feed routes. A request costs
+// as many tokens as the upstream pages it fetches, so /ptt/search?pages=5
+// costs 5 and /ptt/trending costs its trending.pages or max_pages.
+type RateLimitConfig struct {
+	PerMinute      int      `yaml:"per_minute"`      // 每個 IP 每分鐘補充的 token 數，0 表示不限制
+	Burst          int      `yaml:"burst"`           // 最多累積的 token 數，需不小於 trending.max_pages
+	TrustedProxies []string `yaml:"trusted_proxies"` // 信任其 X-Forwarded-For 的代理 (CIDR 或 IP)，例如 Traefik
+}
+
+// ImageProxyConfig controls /img, which serves upstream images from a disk
+// cache, and the rewriting of feed image URLs to point at it. /img only
+// serves URLs signed with auth.signing_secrets, so it is not an open proxy.
+type ImageProxyConfig struct {
+	CacheDir       string `yaml:"cache_dir"`       // 圖片快取目錄
+	CacheSize      int64  `yaml:"cache_size"`      // 快取總大小上限 (bytes)，超過時刪除最久未使用的圖片
+	MaxBytes       int64  `yaml:"max_bytes"`       // 單張上游圖片大小上限 (bytes)
+	Rewrite        bool   `yaml:"rewrite"`         // 將 feed 內的圖片網址改寫為本服務的 /img
+	PublicURL      string `yaml:"public_url"`      // 本服務對外的網址，改寫時使用，例如 https://feed.example.com
+	ThumbnailWidth int    `yaml:"thumbnail_width"` // 改寫後的圖片縮圖寬度 (px)，0 表示原圖
+	AllowPrivate   bool   `yaml:"allow_private"`   // 允許抓取內網位址的圖片，僅供本機測試
+}
+
+// SavedFeedsConfig holds the feeds served at /f/{id}. Feeds listed here are
+// read-only; the admin API (/admin/feeds) manages the ones in File.
+type SavedFeedsConfig struct {
+	File  string           `yaml:"file"`  // 由管理 API 寫入的 JSON 檔，空白則只提供下列 feeds
+	Feeds []savedfeed.Feed `yaml:"feeds"` // 設定檔中的 feed 定義
+}
+
+// BoardProfile tunes viral detection for one board. Zero fields fall back to
+// the trending defaults; 100 pushes is huge on Steam but routine on Gossiping.
+type BoardProfile struct {
+	ViralPushes       int           `yaml:"viral_pushes"`
+	ControversialBoos int           `yaml:"controversial_boos"`
+	PotentialMaxAge   time.Duration `yaml:"potential_max_age"`
+	DefaultThreshold  float64       `yaml:"default_threshold"`
+	Model             string        `yaml:"model"` // 預測服務 models 目錄下的模型檔名，空白則用服務預設模型
+}
+
+// Profile returns the effective profile of board.
+func (c *Config) Profile(board string) BoardProfile {
+	profile := BoardProfile{
+		ViralPushes:       c.Trending.ViralPushes,
+		ControversialBoos: c.Trending.ControversialBoos,
+		PotentialMaxAge:   c.Trending.PotentialMaxAge,
+		DefaultThreshold:  c.Trending.DefaultThreshold,
+	}
+	override, ok := c.Boards[board]
+	if !ok {
+		return profile
+	}
+	if override.ViralPushes > 0 {
+		profile.ViralPushes = override.ViralPushes
+	}
+	if override.ControversialBoos > 0 {
+		profile.ControversialBoos = override.ControversialBoos
+	}
+	if override.PotentialMaxAge > 0 {
+		profile.PotentialMaxAge = override.PotentialMaxAge
+	}
+	if override.DefaultThreshold > 0 {
+		profile.DefaultThreshold = override.DefaultThreshold
+	}
+	profile.Model = override.Model
+	return profile
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      90 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+			ShutdownDelay:     5 * time.Second,
+		},
+		Upstream: UpstreamConfig{
+			Timeout:         15 * time.Second,
+			UserAgent:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36",
+			ArticleCacheTTL: 2 * time.Minute,
+			PttBaseURL:      "https://www.ptt.cc",
+			PlurkBaseURL:    "https://www.plurk.com",
+		},
+		Predict: PredictConfig{
+			URL:        "http://localhost:5000",
+			TimeWindow: 10,
+			Timeout:    5 * time.Second,
+		},
+		Trending: TrendingConfig{
+			ViralPushes:       100,
+			ControversialBoos: 50,
+			PotentialMaxAge:   2 * time.Hour,
+			DefaultThreshold:  0.5,
+			DefaultLimit:      20,
+			Pages:             3,
+			MaxPages:          20,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "go_feed_tool",
+		},
+		Ready: ReadyConfig{
+			Timeout: 3 * time.Second,
+		},
+		Auth: AuthConfig{
+			ReloadInterval: 10 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			PerMinute: 60,
+			Burst:     60,
+			// docker-compose 的 Traefik 位於私有網段
+			TrustedProxies: []string{"127.0.0.0/8", "::1/128"}, // 私有網段需明確設定，否則同網段的用戶端可偽造 X-Forwarded-For
+		},
+		ImageProxy: ImageProxyConfig{
+			// Cloud Functions 只有 /tmp 可寫
+			CacheDir:  filepath.Join(os.TempDir(), "go_feed_tool", "img"),
+			CacheSize: 512 << 20,
+			MaxBytes:  10 << 20,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the YAML file given by
+// -config (or FEED_TOOL_CONFIG), environment variables and flags, then
+// validates the result.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("feed_tool", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("FEED_TOOL_CONFIG"), "path to YAML config file")
+	addr := fs.String("addr", "", "listen address, e.g. :8080")
+	predictURL := fs.String("predict-url", "", "prediction service URL")
+	recordDir := fs.String("record-dir", "", "record upstream exchanges of each request under this directory")
+	replayDir := fs.String("replay-dir", "", "serve upstream responses from a recorded directory")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+	logFormat := fs.String("log-format", "", "log format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.loadFile(*file); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if *addr != "" {
+		cfg.Server.Addr = *addr
+	}
+	if *predictURL != "" {
+		cfg.Predict.URL = *predictURL
+	}
+	if *recordDir != "" {
+		cfg.Debug.RecordDir = *recordDir
+	}
+	if *replayDir != "" {
+		cfg.Debug.ReplayDir = *replayDir
+	}
+	if *logLevel != "" {
+		cfg.Log.Level = *logLevel
+	}
+	if *logFormat != "" {
+		cfg.Log.Format = *logFormat
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FromEnv builds the configuration from defaults and environment variables
+// only. It is used by the Cloud Functions entry points, which have no flags.
+func FromEnv() (*Config, error) {
+	cfg := Default()
+	if file := os.Getenv("FEED_TOOL_CONFIG"); file != "" {
+		if err := cfg.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parse config %s: %w", file, err)
+	}
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	var errs []error
+	envString("FEED_TOOL_ADDR", &c.Server.Addr)
+	errs = append(errs, envDuration("FEED_TOOL_WRITE_TIMEOUT", &c.Server.WriteTimeout))
+	errs = append(errs, envDuration("FEED_TOOL_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout))
+	errs = append(errs, envDuration("FEED_TOOL_SHUTDOWN_DELAY", &c.Server.ShutdownDelay))
+	envString("FEED_TOOL_USER_AGENT", &c.Upstream.UserAgent)
+	errs = append(errs, envDuration("FEED_TOOL_UPSTREAM_TIMEOUT", &c.Upstream.Timeout))
+	errs = append(errs, envDuration("FEED_TOOL_ARTICLE_CACHE_TTL", &c.Upstream.ArticleCacheTTL))
+	envString("FEED_TOOL_PTT_BASE_URL", &c.Upstream.PttBaseURL)
+	envString("FEED_TOOL_PLURK_BASE_URL", &c.Upstream.PlurkBaseURL)
+	envString("PREDICT_SERVICE_URL", &c.Predict.URL)
+	errs = append(errs, envInt("PREDICTION_TIME_WINDOW", &c.Predict.TimeWindow))
+	errs = append(errs, envDuration("PREDICT_SERVICE_TIMEOUT", &c.Predict.Timeout))
+	errs = append(errs, envInt("FEED_TOOL_VIRAL_PUSHES", &c.Trending.ViralPushes))
+	errs = append(errs, envDuration("FEED_TOOL_POTENTIAL_MAX_AGE", &c.Trending.PotentialMaxAge))
+	envString("FEED_TOOL_RECORD_DIR", &c.Debug.RecordDir)
+	envString("FEED_TOOL_REPLAY_DIR", &c.Debug.ReplayDir)
+	envString("FEED_TOOL_LOG_LEVEL", &c.Log.Level)
+	envString("FEED_TOOL_LOG_FORMAT", &c.Log.Format)
+	envString("FEED_TOOL_TRACING_EXPORTER", &c.Tracing.Exporter)
+	envString("FEED_TOOL_TRACING_ENDPOINT", &c.Tracing.Endpoint)
+	errs = append(errs, envFloat("FEED_TOOL_TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio))
+	errs = append(errs, envDuration("FEED_TOOL_READY_TIMEOUT", &c.Ready.Timeout))
+	errs = append(errs, envBool("FEED_TOOL_READY_PROBE_PTT", &c.Ready.ProbePTT))
+	envString("FEED_TOOL_AUTH_KEYS_FILE", &c.Auth.KeysFile)
+	errs = append(errs, envDuration("FEED_TOOL_AUTH_RELOAD_INTERVAL", &c.Auth.ReloadInterval))
+	envList("FEED_TOOL_AUTH_SIGNING_SECRETS", &c.Auth.SigningSecrets)
+	envString("FEED_TOOL_AUTH_ADMIN_TOKEN", &c.Auth.AdminToken)
+	errs = append(errs, envInt("FEED_TOOL_RATE_LIMIT_PER_MINUTE", &c.RateLimit.PerMinute))
+	errs = append(errs, envInt("FEED_TOOL_RATE_LIMIT_BURST", &c.RateLimit.Burst))
+	envList("FEED_TOOL_RATE_LIMIT_TRUSTED_PROXIES", &c.RateLimit.TrustedProxies)
+	envString("FEED_TOOL_IMAGE_PROXY_CACHE_DIR", &c.ImageProxy.CacheDir)
+	errs = append(errs, envInt64("FEED_TOOL_IMAGE_PROXY_CACHE_SIZE", &c.ImageProxy.CacheSize))
+	errs = append(errs, envInt64("FEED_TOOL_IMAGE_PROXY_MAX_BYTES", &c.ImageProxy.MaxBytes))
+	errs = append(errs, envBool("FEED_TOOL_IMAGE_PROXY_REWRITE", &c.ImageProxy.Rewrite))
+	envString("FEED_TOOL_IMAGE_PROXY_PUBLIC_URL", &c.ImageProxy.PublicURL)
+	errs = append(errs, envInt("FEED_TOOL_IMAGE_PROXY_THUMBNAIL_WIDTH", &c.ImageProxy.ThumbnailWidth))
+	envString("FEED_TOOL_SAVED_FEEDS_FILE", &c.SavedFeeds.File)
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	for name, d := range map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if c.Server.ShutdownDelay < 0 || c.Server.ShutdownDelay >= c.Server.ShutdownTimeout {
+		errs = append(errs, errors.New("server.shutdown_delay must be at least 0 and less than server.shutdown_timeout"))
+	}
+	if c.Upstream.Timeout <= 0 {
+		errs = append(errs, errors.New("upstream.timeout must be positive"))
+	}
+	for name, raw := range map[string]string{"upstream.ptt_base_url": c.Upstream.PttBaseURL, "upstream.plurk_base_url": c.Upstream.PlurkBaseURL} {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.HasSuffix(raw, "/") {
+			errs = append(errs, fmt.Errorf("%s %q must be an absolute http(s) URL without trailing slash", name, raw))
+		}
+	}
+	if c.Upstream.ArticleCacheTTL < 0 {
+		errs = append(errs, errors.New("upstream.article_cache_ttl must not be negative"))
+	}
+	if u, err := url.Parse(c.Predict.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("predict.url %q must be an absolute http(s) URL", c.Predict.URL))
+	}
+	switch c.Predict.TimeWindow {
+	case 5, 10, 15:
+	default:
+		errs = append(errs, fmt.Errorf("predict.time_window must be 5, 10 or 15, got %d", c.Predict.TimeWindow))
+	}
+	if c.Predict.Timeout <= 0 {
+		errs = append(errs, errors.New("predict.timeout must be positive"))
+	}
+	if c.Trending.ViralPushes <= 0 {
+		errs = append(errs, errors.New("trending.viral_pushes must be positive"))
+	}
+	if c.Trending.ControversialBoos <= 0 {
+		errs = append(errs, errors.New("trending.controversial_boos must be positive"))
+	}
+	if c.Trending.PotentialMaxAge <= time.Duration(c.Predict.TimeWindow)*time.Minute {
+		errs = append(errs, errors.New("trending.potential_max_age must be longer than predict.time_window"))
+	}
+	if c.Trending.DefaultThreshold < 0 || c.Trending.DefaultThreshold > 1 {
+		errs = append(errs, errors.New("trending.default_threshold must be between 0 and 1"))
+	}
+	if c.Trending.DefaultLimit <= 0 {
+		errs = append(errs, errors.New("trending.default_limit must be positive"))
+	}
+	if c.Trending.Pages <= 0 {
+		errs = append(errs, errors.New("trending.pages must be positive"))
+	}
+	if c.Trending.MaxPages < c.Trending.Pages {
+		errs = append(errs, errors.New("trending.max_pages must be at least trending.pages"))
+	}
+	if c.Debug.RecordDir != "" && c.Debug.ReplayDir != "" {
+		errs = append(errs, errors.New("debug.record_dir and debug.replay_dir cannot both be set"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log.format must be text or json, got %q", c.Log.Format))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	if c.Ready.Timeout <= 0 {
+		errs = append(errs, errors.New("ready.timeout must be positive"))
+	}
+	if c.Auth.ReloadInterval <= 0 {
+		errs = append(errs, errors.New("auth.reload_interval must be positive"))
+	}
+	for i, secret := range c.Auth.SigningSecrets {
+		if len(secret) < minSecretLength {
+			errs = append(errs, fmt.Errorf("auth.signing_secrets[%d] must be at least %d characters", i, minSecretLength))
+		}
+	}
+	if c.Auth.AdminToken != "" && len(c.Auth.AdminToken) < minSecretLength {
+		errs = append(errs, fmt.Errorf("auth.admin_token must be at least %d characters", minSecretLength))
+	}
+	if c.RateLimit.PerMinute < 0 {
+		errs = append(errs, errors.New("rate_limit.per_minute must not be negative"))
+	}
+	if c.RateLimit.PerMinute > 0 && c.RateLimit.Burst < c.Trending.MaxPages {
+		// 否則最貴的 trending 請求永遠無法通過
+		errs = append(errs, errors.New("rate_limit.burst must be at least trending.max_pages"))
+	}
+	if _, err := ratelimit.ParseProxies(c.RateLimit.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies: %w", err))
+	}
+	if c.ImageProxy.CacheDir == "" {
+		errs = append(errs, errors.New("image_proxy.cache_dir must not be empty"))
+	}
+	if c.ImageProxy.MaxBytes <= 0 {
+		errs = append(errs, errors.New("image_proxy.max_bytes must be positive"))
+	}
+	if c.ImageProxy.CacheSize < c.ImageProxy.MaxBytes {
+		errs = append(errs, errors.New("image_proxy.cache_size must be at least image_proxy.max_bytes"))
+	}
+	if w := c.ImageProxy.ThumbnailWidth; w != 0 && (w < imgproxy.MinWidth || w > imgproxy.MaxWidth) {
+		errs = append(errs, fmt.Errorf("image_proxy.thumbnail_width must be 0 or between %d and %d", imgproxy.MinWidth, imgproxy.MaxWidth))
+	}
+	if c.ImageProxy.Rewrite {
+		if u, err := url.Parse(c.ImageProxy.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.HasSuffix(c.ImageProxy.PublicURL, "/") {
+			errs = append(errs, fmt.Errorf("image_proxy.public_url %q must be an absolute http(s) URL without trailing slash when image_proxy.rewrite is set", c.ImageProxy.PublicURL))
+		}
+		if len(c.Auth.SigningSecrets) == 0 {
+			// 改寫後的網址需要簽章，否則 /img 一律拒絕
+			errs = append(errs, errors.New("image_proxy.rewrite requires auth.signing_secrets"))
+		}
+	}
+	ids := make(map[string]bool)
+	for i, f := range c.SavedFeeds.Feeds {
+		if err := savedfeed.CheckID(f.ID); err != nil {
+			errs = append(errs, fmt.Errorf("saved_feeds.feeds[%d]: %w", i, err))
+		} else if ids[f.ID] {
+			errs = append(errs, fmt.Errorf("saved_feeds.feeds[%d].id %q is duplicated", i, f.ID))
+		}
+		ids[f.ID] = true
+		// 來源路由與參數由 handler.Configure 依路由表檢查
+		switch {
+		case f.Composite() && f.Source != "":
+			errs = append(errs, fmt.Errorf("saved_feeds.feeds[%d] must set either source or sources, not both", i))
+		case f.Composite():
+			for j, s := range f.Sources {
+				if !strings.HasPrefix(s.Source, "/") {
+					errs = append(errs, fmt.Errorf("saved_feeds.feeds[%d].sources[%d].source must be a feed route such as /ptt/search", i, j))
+				}
+			}
+		case !strings.HasPrefix(f.Source, "/"):
+			errs = append(errs, fmt.Errorf("saved_feeds.feeds[%d].source must be a feed route such as /ptt/trending", i))
+		}
+	}
+	for board, profile := range c.Boards {
+		if profile.ViralPushes < 0 {
+			errs = append(errs, fmt.Errorf("boards.%s.viral_pushes must not be negative", board))
+		}
+		if profile.ControversialBoos < 0 {
+			errs = append(errs, fmt.Errorf("boards.%s.controversial_boos must not be negative", board))
+		}
+		if profile.PotentialMaxAge < 0 {
+			errs = append(errs, fmt.Errorf("boards.%s.potential_max_age must not be negative", board))
+		}
+		if profile.DefaultThreshold < 0 || profile.DefaultThreshold > 1 {
+			errs = append(errs, fmt.Errorf("boards.%s.default_threshold must be between 0 and 1", board))
+		}
+		if profile.Model != "" && (profile.Model != path.Base(profile.Model) || !strings.HasSuffix(profile.Model, ".json")) {
+			errs = append(errs, fmt.Errorf("boards.%s.model must be a .json file name without directories", board))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// minSecretLength is the shortest accepted signing secret or admin token.
+const minSecretLength = 16
+
+// Redacted returns a copy that is safe to show on /debug/config.
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Predict.URL = redactURL(r.Predict.URL)
+	r.Auth.SigningSecrets = nil
+	for range c.Auth.SigningSecrets {
+		r.Auth.SigningSecrets = append(r.Auth.SigningSecrets, "REDACTED")
+	}
+	if r.Auth.AdminToken != "" {
+		r.Auth.AdminToken = "REDACTED"
+	}
+	return &r
+}
+
+// YAML renders the configuration in the same format as the config file.
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = url.User("REDACTED")
+	return u.String()
+}
+
+func envString(key string, dst *string) {
+	if value := os.Getenv(key); value != "" {
+		*dst = value
+	}
+}
+
+// envList reads a comma-separated list, e.g. several signing secrets.
+func envList(key string, dst *[]string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	*dst = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*dst = append(*dst, item)
+		}
+	}
+}
+
+func envInt(key string, dst *int) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = v
+	return nil
+}
+
+func envInt64(key string, dst *int64) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = v
+	return nil
+}
+
+func envBool(key string, dst *bool) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = v
+	return nil
+}
+
+func envFloat(key string, dst *float64) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = v
+	return nil
+}
+
+func envDuration(key string, dst *time.Duration) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = v
+	return nil
+}
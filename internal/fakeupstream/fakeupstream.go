@@ -0,0 +1,163 @@
+// Package fakeupstream serves recorded ptt.cc and plurk.com responses from
+// local fixtures so feed handlers can be tested without network access.
+//
+// Fixtures live in testdata/ and mirror the upstream paths:
+//
+//	testdata/ptt/bbs/{board}/index.html          board index (newest page)
+//	testdata/ptt/bbs/{board}/index{N}.html       older index pages
+//	testdata/ptt/bbs/{board}/search-page{N}.html search results, any keyword
+//	testdata/ptt/bbs/{board}/M.*.html            article pages
+//	testdata/plurk/search2.json                  POST /Search/search2
+//	testdata/plurk/Stats/{qType}.json            GET /Stats/{qType}
+package fakeupstream
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+//go:embed testdata
+var fixtures embed.FS
+
+// Over18Boards are gated behind the /ask/over18 page unless the request
+// carries the over18=1 cookie, like on ptt.cc.
+var Over18Boards = map[string]bool{
+	"Gossiping": true,
+}
+
+// Server is a running fake upstream that records the requests it served.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+}
+
+// Requests returns the request URIs served so far, e.g.
+// "GET /bbs/C_Chat/index.html".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) record(r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+}
+
+// NewPTT starts a fake www.ptt.cc.
+func NewPTT() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		servePTT(w, r)
+	}))
+	return s
+}
+
+// NewPlurk starts a fake www.plurk.com.
+func NewPlurk() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		servePlurk(w, r)
+	}))
+	return s
+}
+
+func servePTT(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ask/over18" {
+		serveOver18(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "bbs" {
+		http.NotFound(w, r)
+		return
+	}
+	board, name := parts[1], parts[2]
+
+	if Over18Boards[board] {
+		if cookie, err := r.Cookie("over18"); err != nil || cookie.Value != "1" {
+			http.Redirect(w, r, "/ask/over18?from="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+	}
+
+	if name == "search" {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		name = "search-page" + page + ".html"
+	}
+
+	serveFixture(w, r, "testdata/ptt/bbs/"+board+"/"+name, "text/html; charset=utf-8")
+}
+
+func serveOver18(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		r.ParseForm()
+		if r.PostForm.Get("yes") == "yes" {
+			http.SetCookie(w, &http.Cookie{Name: "over18", Value: "1", Path: "/"})
+			http.Redirect(w, r, r.PostForm.Get("from"), http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/bbs/", http.StatusFound)
+		return
+	}
+
+	page, err := fixtures.ReadFile("testdata/ptt/ask/over18.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	from := url.QueryEscape(r.URL.Query().Get("from"))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(strings.Replace(string(page), "{from}", from, 1)))
+}
+
+func servePlurk(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/Search/search2":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.ParseForm()
+		if r.PostForm.Get("query") == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"error":"empty query"}`))
+			return
+		}
+		serveFixture(w, r, "testdata/plurk/search2.json", "application/json")
+	case strings.HasPrefix(r.URL.Path, "/Stats/"):
+		qType := strings.TrimPrefix(r.URL.Path, "/Stats/")
+		serveFixture(w, r, "testdata/plurk/Stats/"+qType+".json", "application/json")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func serveFixture(w http.ResponseWriter, r *http.Request, name string, contentType string) {
+	if !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := fixtures.ReadFile(name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("404 - Not Found. (%s)", r.URL.Path), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
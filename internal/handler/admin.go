@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AdminRoutes are server-only management endpoints. They require the
+// auth.admin_token bearer token and are disabled without one.
+var AdminRoutes = []Route{
+	{
+		Path:        "/admin/sign",
+		Summary:     "產生簽章 feed 網址",
+		Description: "簽章網址不需要 API key 即可訂閱；移除 auth.signing_secrets 中的密鑰即撤銷其簽出的所有網址。",
+		Admin:       true,
+		ContentType: "application/json",
+		Params: []Param{
+			{Name: "url", Type: ParamString, Required: true, Description: "要簽章的 feed 網址，例如 /ptt/trending?board=C_Chat"},
+			{Name: "ttl", Type: ParamDuration, Min: bound(60), Description: "有效期限，空白則不會過期"},
+		},
+		Handler: GetAdminSign,
+	},
+	{
+		Path:        "/admin/feeds",
+		Summary:     "列出已儲存的 feed",
+		Description: "包含設定檔 saved_feeds.feeds 中的定義 (read_only)。",
+		Admin:       true,
+		ContentType: "application/json",
+		Handler:     GetAdminFeeds,
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/admin/feeds",
+		Summary:     "新增已儲存的 feed",
+		Description: "body 為 {id, title, source, params, format}；未指定 id 時產生 8 字元的隨機 ID。定義依來源路由檢查，不接受的參數回 400。",
+		Admin:       true,
+		ContentType: "application/json",
+		Handler:     PostAdminFeeds,
+	},
+	{
+		Path:        "/admin/feeds/{id}",
+		Summary:     "取得已儲存的 feed",
+		Admin:       true,
+		ContentType: "application/json",
+		Params:      []Param{savedFeedIDParam},
+		Handler:     GetAdminFeed,
+	},
+	{
+		Method:      http.MethodPut,
+		Path:        "/admin/feeds/{id}",
+		Summary:     "新增或取代已儲存的 feed",
+		Description: "訂閱 /f/{id} 的閱讀器下次抓取即套用新定義，不需重新訂閱。",
+		Admin:       true,
+		ContentType: "application/json",
+		Params:      []Param{savedFeedIDParam},
+		Handler:     PutAdminFeed,
+	},
+	{
+		Method:      http.MethodDelete,
+		Path:        "/admin/feeds/{id}",
+		Summary:     "刪除已儲存的 feed",
+		Admin:       true,
+		ContentType: "application/json",
+		Params:      []Param{savedFeedIDParam},
+		Handler:     DeleteAdminFeed,
+	},
+}
+
+var savedFeedIDParam = Param{Name: "id", In: InPath, Type: ParamString, Required: true, Description: "feed ID"}
+
+// SignedURL is the JSON body of /admin/sign.
+type SignedURL struct {
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// GetAdminSign handles GET /admin/sign?url=/ptt/trending?board=C_Chat&ttl=720h
+func GetAdminSign(w http.ResponseWriter, r *http.Request) {
+	var ttl time.Duration
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		ttl, _ = time.ParseDuration(v) // 已由 Validate 檢查
+	}
+	signed, err := SignFeedURL(r.URL.Query().Get("url"), ttl)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(signed)
+}
+
+// SignFeedURL signs rawURL, a feed route path with its parameters such as
+// /f/{id}, or a full URL on this service, valid for ttl (forever when 0). The
+// parameters are validated first so a signed link never turns out to be a
+// 400.
+func SignFeedURL(rawURL string, ttl time.Duration) (SignedURL, error) {
+	if signer == nil {
+		return SignedURL{}, errors.New("error: signed URLs are not enabled, set auth.signing_secrets")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return SignedURL{}, &ParamError{Param: "url", Message: err.Error()}
+	}
+	route, ok := feedRoute(u.Path)
+	if !ok {
+		return SignedURL{}, &ParamError{Param: "url", Message: fmt.Sprintf("%s is not a feed route", u.Path)}
+	}
+	if errs := route.CheckParams(u.Query()); len(errs) > 0 {
+		return SignedURL{}, paramsError("url", errs)
+	}
+
+	var signed SignedURL
+	var expires time.Time
+	if ttl > 0 {
+		expires = Now().Add(ttl).Truncate(time.Second)
+		signed.ExpiresAt = &expires
+	}
+	signed.URL = signer.Sign(u, expires).String()
+	return signed, nil
+}
+
+// feedRoute returns the feed route serving path. /f/{id} matches only
+// saved feeds that exist.
+func feedRoute(path string) (Route, bool) {
+	if id, ok := strings.CutPrefix(path, "/f/"); ok {
+		if _, exists := savedFeeds.Get(id); !exists {
+			return Route{}, false
+		}
+		path = "/f/{id}"
+	}
+	for _, route := range Routes {
+		if route.Feed && route.Path == path {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// requireAdmin rejects requests without the admin bearer token.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := current.Auth.AdminToken
+		if token == "" {
+			writeJSONError(w, http.StatusNotFound, "admin API is disabled, set auth.admin_token")
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "invalid admin token")
+			return
+		}
+		next(w, r)
+	}
+}
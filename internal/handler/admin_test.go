@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+)
+
+const testAdminToken = "admin-token-0123456789"
+
+func TestAdminSign(t *testing.T) {
+	original := current
+	defer Configure(original)
+	cfg := config.Default()
+	cfg.Auth.SigningSecrets = []string{"signing-secret-0123456789"}
+	cfg.Auth.AdminToken = testAdminToken
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	sign := Mount(AdminRoutes[0])
+
+	tests := []struct {
+		name           string
+		token          string
+		query          string
+		expectedStatus int
+	}{
+		{"missing token", "", "url=/ptt/trending?board=C_Chat", 401},
+		{"wrong token", "wrong-token-0123456789", "url=/ptt/trending?board=C_Chat", 401},
+		{"feed route", testAdminToken, "url=" + url.QueryEscape("/ptt/trending?board=C_Chat&mode=all"), 200},
+		{"with ttl", testAdminToken, "url=" + url.QueryEscape("/ptt/trending?board=C_Chat") + "&ttl=720h", 200},
+		{"not a feed route", testAdminToken, "url=/ready", 400},
+		{"invalid feed params", testAdminToken, "url=" + url.QueryEscape("/ptt/search?page=0"), 400},
+		{"ttl too short", testAdminToken, "url=/ptt/trending?board=C_Chat&ttl=1s", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/sign?"+tt.query, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			sign(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var signed SignedURL
+			if err := json.Unmarshal(w.Body.Bytes(), &signed); err != nil {
+				t.Fatal(err)
+			}
+			u, _ := url.Parse(signed.URL)
+			if err := signer.Verify(u, time.Now()); err != nil {
+				t.Errorf("minted URL %s does not verify: %v", signed.URL, err)
+			}
+			if hasTTL := tt.name == "with ttl"; hasTTL != (signed.ExpiresAt != nil) {
+				t.Errorf("expires_at = %v", signed.ExpiresAt)
+			}
+		})
+	}
+}
+
+// 沒有設定 auth.admin_token 時管理 API 停用
+func TestAdminDisabled(t *testing.T) {
+	original := current
+	defer Configure(original)
+	if err := Configure(config.Default()); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/admin/sign?url=/ptt/trending?board=C_Chat", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	Mount(AdminRoutes[0])(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+// 簽章網址不需要 API key；簽章錯誤回 403
+func TestMountSignedURL(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.yaml")
+	os.WriteFile(keysFile, []byte("keys:\n  - name: reader\n    key: reader-key-0123456789\n"), 0o600)
+
+	original := current
+	defer Configure(original)
+	cfg := config.Default()
+	cfg.Auth.KeysFile = keysFile
+	cfg.Auth.SigningSecrets = []string{"signing-secret-0123456789"}
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := http.NewServeMux()
+	for _, route := range Routes {
+		routes.HandleFunc(route.Path, Mount(route))
+	}
+	// 簽章後仍會驗證參數；以缺少 board 的網址確認簽章通過又不打上游
+	unsigned, _ := url.Parse("/ptt/search?keyword=test")
+	signed := signer.Sign(unsigned, time.Time{}).String()
+	expired := signer.Sign(unsigned, time.Now().Add(-time.Minute)).String()
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{"valid signature", signed, 400},
+		{"tampered", signed + "&board=C_Chat", 403},
+		{"expired", expired, 403},
+		{"bad signature", "/ptt/search?board=C_Chat&sig=bm9wZQ", 403},
+		{"no signature needs a key", "/ptt/search?keyword=test", 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pageCache keeps recently fetched article pages keyed by URL so that
+// overlapping feed requests (search + trending, or readers polling the same
+// board) don't download the same article again within the TTL.
+type pageCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]pageCacheEntry
+}
+
+type pageCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// maxCachedPages bounds memory; expired entries are swept when it is reached.
+const maxCachedPages = 2000
+
+var articlePages = newPageCache(current.Upstream.ArticleCacheTTL)
+
+func newPageCache(ttl time.Duration) *pageCache {
+	return &pageCache{ttl: ttl, entries: make(map[string]pageCacheEntry)}
+}
+
+func (c *pageCache) get(url string) ([]byte, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[url]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (c *pageCache) put(url string, body []byte) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxCachedPages {
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxCachedPages {
+			c.entries = make(map[string]pageCacheEntry)
+		}
+	}
+	c.entries[url] = pageCacheEntry{body: body, expires: now.Add(c.ttl)}
+}
+
+// fetchArticleDoc returns the parsed article page, from cache when fresh.
+func (p *PttParser) fetchArticleDoc(ctx context.Context, url string) (*goquery.Document, error) {
+	body, ok := articlePages.get(url)
+	metrics.ObserveCache("ptt_article", ok)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache_hit", ok))
+	if !ok {
+		resp, err := p.pttGet(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("ptt returned %d for %s", resp.StatusCode, url)
+		}
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		articlePages.put(url, body)
+	}
+	return goquery.NewDocumentFromReader(bytes.NewReader(body))
+}
+
+// stats returns the number of cached pages and the TTL.
+func (c *pageCache) stats() (int, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries), c.ttl
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchArticleDocUsesCache(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`<div id="main-content">hello</div>`))
+	}))
+	defer server.Close()
+
+	original := articlePages
+	articlePages = newPageCache(time.Minute)
+	defer func() { articlePages = original }()
+
+	parser := NewPttParser(server.Client())
+	for i := 0; i < 3; i++ {
+		doc, err := parser.fetchArticleDoc(context.Background(), server.URL+"/bbs/C_Chat/M.1.A.html")
+		if err != nil {
+			t.Fatalf("fetchArticleDoc() error = %v", err)
+		}
+		if text := doc.Find("div#main-content").Text(); text != "hello" {
+			t.Errorf("content = %q, want hello", text)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("upstream hits = %d, want 1", hits)
+	}
+}
+
+func TestPageCacheExpiry(t *testing.T) {
+	cache := newPageCache(time.Millisecond)
+	cache.put("a", []byte("x"))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.get("a"); ok {
+		t.Error("expired entry returned from cache")
+	}
+
+	disabled := newPageCache(0)
+	disabled.put("a", []byte("x"))
+	if _, ok := disabled.get("a"); ok {
+		t.Error("cache with zero TTL should not store entries")
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/savedfeed"
+	"github.com/Harrison-Dev/go_feed_tool/internal/tracing"
+	"github.com/gorilla/feeds"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// maxCompositeSources bounds the fan-out of one composite feed request.
+	maxCompositeSources   = 10
+	defaultCompositeLimit = 50
+	maxCompositeLimit     = 200
+)
+
+// serveComposite answers /f/{id} for a composite saved feed.
+func serveComposite(w http.ResponseWriter, r *http.Request, f savedfeed.Feed) {
+	feed, err := buildComposite(r, f)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = f.Format
+	}
+	// 指標以路由樣板為標籤，避免每個 ID 各一條時間序列
+	req := r.Clone(r.Context())
+	req.URL.Path = "/f/{id}"
+	req.URL.RawQuery = url.Values{"format": {format}}.Encode()
+	WriteFeed(w, req, feed)
+}
+
+// buildComposite fetches the sources of f concurrently and merges their
+// items newest first. An item linked from several sources is kept once,
+// from the source listed first. A failed source becomes an error item at
+// the top, outside the limit, so readers see it without losing the rest of
+// the feed; only when every source fails is the request an error.
+func buildComposite(r *http.Request, f savedfeed.Feed) (*feeds.Feed, error) {
+	type result struct {
+		feed *feeds.Feed
+		err  error
+	}
+	results := make([]result, len(f.Sources))
+	var wg sync.WaitGroup
+	for i, src := range f.Sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, span := tracing.Start(r.Context(), "composite.source",
+				attribute.String("source", src.Source), attribute.Int("index", i))
+			rt, req, err := sourceRequest(r.WithContext(ctx), src.Source, src.Query(f.Format))
+			var feed *feeds.Feed
+			if err == nil {
+				feed, err = buildFeed(req, rt.Build)
+			}
+			tracing.End(span, err)
+			results[i] = result{feed, err}
+		}()
+	}
+	wg.Wait()
+
+	merged := &feeds.Feed{
+		Title:       f.Title,
+		Description: fmt.Sprintf("Composite feed of %d sources", len(f.Sources)),
+		Author:      &feeds.Author{Name: "Feed Generator"},
+		Created:     Now(),
+	}
+	if merged.Title == "" {
+		merged.Title = "Composite feed - " + f.ID
+	}
+	seen := make(map[string]bool)
+	var errs []error
+	var errorItems []*feeds.Item
+	for i, res := range results {
+		src := f.Sources[i]
+		if res.err != nil {
+			logging.FromRequest(r).Warn("組合 feed 來源失敗", "feed", f.ID, "source", src.Source, "err", res.err)
+			errs = append(errs, fmt.Errorf("%s: %w", src.Source, res.err))
+			errorItems = append(errorItems, sourceErrorItem(f.ID, i, src, res.err))
+			continue
+		}
+		if merged.Link == nil {
+			merged.Link = res.feed.Link
+		}
+		for _, item := range res.feed.Items {
+			if item.Link != nil {
+				key := canonicalURL(item.Link.Href)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			merged.Items = append(merged.Items, item)
+		}
+	}
+	if len(errs) == len(f.Sources) {
+		return nil, fmt.Errorf("error: every source of %s failed: %w", f.ID, errors.Join(errs...))
+	}
+	if merged.Link == nil {
+		merged.Link = &feeds.Link{Href: pttOrigin}
+	}
+
+	sort.SliceStable(merged.Items, func(i, j int) bool {
+		return itemTime(merged.Items[i]).After(itemTime(merged.Items[j]))
+	})
+	limit := f.Limit
+	if limit == 0 {
+		limit = defaultCompositeLimit
+	}
+	if len(merged.Items) > limit {
+		merged.Items = merged.Items[:limit]
+	}
+	// 錯誤項目的時間以小時為單位，排序後可能被 limit 截掉，因此放在最前面
+	merged.Items = append(errorItems, merged.Items...)
+	return merged, nil
+}
+
+func itemTime(item *feeds.Item) time.Time {
+	if item.Created.IsZero() {
+		return item.Updated
+	}
+	return item.Created
+}
+
+// sourceErrorItem reports a failed source of a composite feed. Its ID and
+// time change once an hour, so a source that stays down shows up as one
+// item per hour rather than one per fetch.
+func sourceErrorItem(id string, i int, src savedfeed.Source, err error) *feeds.Item {
+	hour := Now().Truncate(time.Hour)
+	return &feeds.Item{
+		Id:          fmt.Sprintf("urn:go_feed_tool:%s:error:%d:%d", id, i, hour.Unix()),
+		Title:       fmt.Sprintf("[錯誤] 來源 %s 無法取得", src.Source),
+		Link:        &feeds.Link{Href: sourceLink(src)},
+		Description: html.EscapeString(err.Error()),
+		Author:      &feeds.Author{Name: "Feed Generator"},
+		Created:     hour,
+	}
+}
+
+// sourceLink is the site a source reads from, for the link of its error item.
+func sourceLink(src savedfeed.Source) string {
+	if board := src.Params["board"]; board != "" {
+		return pttOrigin + "/bbs/" + url.PathEscape(board) + "/index.html"
+	}
+	if strings.HasPrefix(src.Source, "/plurk/") {
+		return plurkOrigin
+	}
+	return pttOrigin
+}
+
+// canonicalURL normalizes an item link for deduplication: the same PTT
+// article is linked as www.ptt.cc or bbs.beptt.cc depending on the route,
+// and tracking parameters or fragments do not make a different page.
+func canonicalURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = "https"
+	u.Host = strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	if u.Host == "bbs.beptt.cc" {
+		u.Host = "ptt.cc"
+		u.Path = "/bbs" + u.Path
+	}
+	u.Fragment = ""
+	q := u.Query()
+	for name := range q {
+		if strings.HasPrefix(strings.ToLower(name), "utm_") {
+			q.Del(name)
+		}
+	}
+	u.RawQuery = q.Encode()
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String()
+}
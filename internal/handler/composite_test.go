@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/savedfeed"
+	"github.com/gorilla/feeds"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"https://bbs.beptt.cc/C_Chat/M.1700000000.A.123.html", "https://www.ptt.cc/bbs/C_Chat/M.1700000000.A.123.html"},
+		{"http://WWW.PTT.CC/bbs/C_Chat/M.1.A.1.html#push", "https://www.ptt.cc/bbs/C_Chat/M.1.A.1.html"},
+		{"https://www.plurk.com/p/abc?utm_source=rss&utm_medium=feed", "https://www.plurk.com/p/abc/"},
+	}
+	for _, tt := range tests {
+		if a, b := canonicalURL(tt.a), canonicalURL(tt.b); a != b {
+			t.Errorf("canonicalURL(%s) = %s, canonicalURL(%s) = %s; want equal", tt.a, a, tt.b, b)
+		}
+	}
+	if canonicalURL("https://www.ptt.cc/bbs/C_Chat/M.1.A.1.html") == canonicalURL("https://www.ptt.cc/bbs/C_Chat/M.2.A.2.html") {
+		t.Error("different articles share a canonical URL")
+	}
+}
+
+func TestBuildComposite(t *testing.T) {
+	now := time.Date(2026, 1, 22, 20, 59, 0, 0, time.UTC)
+	originalNow := Now
+	defer func() { Now = originalNow }()
+	Now = func() time.Time { return now }
+
+	item := func(title, link string, age time.Duration) *feeds.Item {
+		return &feeds.Item{Title: title, Link: &feeds.Link{Href: link}, Created: now.Add(-age)}
+	}
+	fake := func(items ...*feeds.Item) Route {
+		return Route{Feed: true, Build: func(*http.Request, *Filter) (*feeds.Feed, error) {
+			return &feeds.Feed{Link: &feeds.Link{Href: pttOrigin}, Items: items}, nil
+		}}
+	}
+	// 同一篇文章在兩個來源以不同網域出現
+	sources["/test/a"] = fake(
+		item("a: 芙莉蓮", "https://www.ptt.cc/bbs/C_Chat/M.3.A.3.html", time.Minute),
+		item("a: 舊文", "https://www.ptt.cc/bbs/C_Chat/M.1.A.1.html", 3*time.Minute),
+	)
+	sources["/test/b"] = fake(
+		item("b: 芙莉蓮", "https://bbs.beptt.cc/C_Chat/M.3.A.3.html", time.Minute),
+		item("b: 新文", "https://bbs.beptt.cc/C_Chat/M.2.A.2.html", 2*time.Minute),
+	)
+	sources["/test/fail"] = Route{Feed: true, Build: func(*http.Request, *Filter) (*feeds.Feed, error) {
+		return nil, errors.New("ptt returned 503")
+	}}
+	defer func() {
+		delete(sources, "/test/a")
+		delete(sources, "/test/b")
+		delete(sources, "/test/fail")
+	}()
+
+	tests := []struct {
+		name       string
+		sources    []string
+		limit      int
+		wantTitles []string
+		wantErr    string
+	}{
+		{"dedupe keeps the first source", []string{"/test/a", "/test/b"}, 0,
+			[]string{"a: 芙莉蓮", "b: 新文", "a: 舊文"}, ""},
+		{"source order decides precedence", []string{"/test/b", "/test/a"}, 0,
+			[]string{"b: 芙莉蓮", "b: 新文", "a: 舊文"}, ""},
+		{"global limit", []string{"/test/a", "/test/b"}, 2,
+			[]string{"a: 芙莉蓮", "b: 新文"}, ""},
+		// 錯誤項目的時間是整點，比其他項目舊，但不受 limit 影響
+		{"partial failure", []string{"/test/a", "/test/fail"}, 1,
+			[]string{"[錯誤] 來源 /test/fail 無法取得", "a: 芙莉蓮"}, ""},
+		{"every source failed", []string{"/test/fail", "/test/fail"}, 0,
+			nil, "every source of team failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := savedfeed.Feed{ID: "team", Limit: tt.limit}
+			for _, src := range tt.sources {
+				f.Sources = append(f.Sources, savedfeed.Source{Source: src})
+			}
+			feed, err := buildComposite(httptest.NewRequest("GET", "/f/team", nil), f)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("buildComposite() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var titles []string
+			for _, item := range feed.Items {
+				titles = append(titles, item.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("titles = %q, want %q", titles, tt.wantTitles)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/auth"
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/Harrison-Dev/go_feed_tool/internal/httprec"
+	"github.com/Harrison-Dev/go_feed_tool/internal/imgproxy"
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/ratelimit"
+	"github.com/Harrison-Dev/go_feed_tool/internal/tracing"
+)
+
+// current holds the settings used by the handlers. Cloud Functions get the
+// environment-derived configuration; the server replaces it via Configure.
+var current = loadEnvConfig()
+
+type configKey struct{}
+
+// withConfig makes the route costs of r use cfg instead of current, for
+// checking saved feeds against a config that is not applied yet.
+func withConfig(r *http.Request, cfg *config.Config) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), configKey{}, cfg))
+}
+
+// requestConfig returns the config attached by withConfig, or current.
+func requestConfig(r *http.Request) *config.Config {
+	if cfg, ok := r.Context().Value(configKey{}).(*config.Config); ok {
+		return cfg
+	}
+	return current
+}
+
+// PredictService URL (configured via Configure or environment variable)
+var PredictServiceURL = current.Predict.URL
+var predictionTimeWindow = current.Predict.TimeWindow // minutes; should match model
+
+// replayer serves upstream responses when debug.replay_dir is set.
+var replayer = loadReplayer(current)
+
+// keys authenticates feed requests when auth.keys_file is set.
+var keys = loadKeys(current)
+
+// signer verifies signed feed URLs; nil without auth.signing_secrets.
+var signer = auth.NewSigner(current.Auth.SigningSecrets)
+
+// clients limits each client IP on the feed routes; nil when
+// rate_limit.per_minute is 0.
+var clients = newClientLimiter(current)
+
+// proxies are trusted to report the client IP in X-Forwarded-For.
+var proxies, _ = ratelimit.ParseProxies(current.RateLimit.TrustedProxies)
+
+// images caches the images served by /img.
+var images = imgproxy.NewCache(current.ImageProxy.CacheDir, current.ImageProxy.CacheSize)
+
+// recordSeq keeps record session directories unique within a millisecond.
+var recordSeq atomic.Int64
+
+func loadEnvConfig() *config.Config {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		slog.Warn("設定錯誤，改用預設值", "err", err)
+		return config.Default()
+	}
+	return cfg
+}
+
+func loadReplayer(cfg *config.Config) *httprec.Replayer {
+	if cfg.Debug.ReplayDir == "" {
+		return nil
+	}
+	r, err := httprec.NewReplayer(cfg.Debug.ReplayDir)
+	if err != nil {
+		// 改用空的錄製: 所有上游請求都失敗，而不是悄悄改連 ptt.cc 與 plurk.com
+		slog.Error("重播目錄載入失敗", "dir", cfg.Debug.ReplayDir, "err", err)
+		return &httprec.Replayer{}
+	}
+	return r
+}
+
+func loadKeys(cfg *config.Config) *auth.Store {
+	if cfg.Auth.KeysFile == "" {
+		return nil
+	}
+	s, err := auth.Load(cfg.Auth.KeysFile, cfg.Auth.ReloadInterval)
+	if err != nil {
+		// 沿用空的 key store: 在 key 檔修好前拒絕所有 feed 請求，而不是對外開放
+		slog.Error("API key 檔載入失敗", "file", cfg.Auth.KeysFile, "err", err)
+	}
+	return s
+}
+
+func newClientLimiter(cfg *config.Config) *ratelimit.Limiter {
+	if cfg.RateLimit.PerMinute <= 0 {
+		return nil
+	}
+	return ratelimit.NewLimiter(cfg.RateLimit.PerMinute, cfg.RateLimit.Burst)
+}
+
+// Configure replaces the handler settings with cfg.
+func Configure(cfg *config.Config) error {
+	var r *httprec.Replayer
+	if cfg.Debug.ReplayDir != "" {
+		var err error
+		if r, err = httprec.NewReplayer(cfg.Debug.ReplayDir); err != nil {
+			return err
+		}
+	}
+
+	var k *auth.Store
+	if cfg.Auth.KeysFile != "" {
+		var err error
+		if k, err = auth.Load(cfg.Auth.KeysFile, cfg.Auth.ReloadInterval); err != nil {
+			return err
+		}
+	}
+
+	p, err := ratelimit.ParseProxies(cfg.RateLimit.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	s, err := openSavedFeeds(cfg)
+	if err != nil {
+		return err
+	}
+
+	current = cfg
+	keys = k
+	clients = newClientLimiter(cfg)
+	proxies = p
+	signer = auth.NewSigner(cfg.Auth.SigningSecrets)
+	images = imgproxy.NewCache(cfg.ImageProxy.CacheDir, cfg.ImageProxy.CacheSize)
+	savedFeeds = s
+	PredictServiceURL = cfg.Predict.URL
+	predictionTimeWindow = cfg.Predict.TimeWindow
+	articlePages = newPageCache(cfg.Upstream.ArticleCacheTTL)
+	replayer = r
+	return nil
+}
+
+// NewUpstreamClient returns the client for ptt.cc and plurk.com requests made
+// while serving r (which may be nil for background work). With
+// debug.record_dir set, every exchange is written to a directory named after
+// the request; with debug.replay_dir set, responses come from a recording.
+func NewUpstreamClient(r *http.Request) *http.Client {
+	var transport http.RoundTripper
+	switch {
+	case current.Debug.ReplayDir != "":
+		transport = replayer
+	case current.Debug.RecordDir != "":
+		route := "background"
+		if r != nil {
+			route = r.URL.Path
+		}
+		stamp := fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405.000"), recordSeq.Add(1))
+		transport = httprec.NewRecorder(httprec.SessionDir(current.Debug.RecordDir, stamp, route), nil)
+	}
+	transport = logging.Transport(logging.FromRequest(r), transport)
+	return &http.Client{Timeout: current.Upstream.Timeout, Transport: tracing.Transport(metrics.Transport(upstreamCounter{transport}))}
+}
+
+// newImageClient returns the client for /img requests. Image URLs come from
+// article bodies, so unless image_proxy.allow_private is set it refuses
+// non-public addresses. Images are not recorded or replayed, and their
+// hosts are unbounded, so metrics count them all as host image_proxy.
+func newImageClient(r *http.Request) *http.Client {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if !current.ImageProxy.AllowPrivate {
+		base.DialContext = imgproxy.PublicDialer().DialContext
+	}
+	transport := logging.Transport(logging.FromRequest(r), base)
+	return &http.Client{Timeout: current.Upstream.Timeout, Transport: tracing.Transport(metrics.TransportAs("image_proxy", transport))}
+}
+
+// newPredictClient returns a client for the prediction service.
+func newPredictClient() *http.Client {
+	return &http.Client{Timeout: current.Predict.Timeout, Transport: tracing.InternalTransport(nil)}
+}
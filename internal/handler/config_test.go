@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/Harrison-Dev/go_feed_tool/internal/httprec"
+)
+
+func TestReplayFailsClosed(t *testing.T) {
+	original, originalReplayer := current, replayer
+	defer func() { current, replayer = original, originalReplayer }()
+
+	// 載入失敗的重播目錄不能讓請求改連真正的上游
+	cfg := config.Default()
+	cfg.Debug.ReplayDir = filepath.Join(t.TempDir(), "missing")
+	current, replayer = cfg, loadReplayer(cfg)
+
+	_, err := NewUpstreamClient(nil).Get(pttOrigin + "/bbs/C_Chat/index.html")
+	if !errors.Is(err, httprec.ErrNotRecorded) {
+		t.Errorf("err = %v, want ErrNotRecorded", err)
+	}
+	if err := Configure(cfg); err == nil {
+		t.Error("Configure accepted a missing replay_dir")
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/sanitize"
+	"github.com/Harrison-Dev/go_feed_tool/internal/tracing"
+	"github.com/gorilla/feeds"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Now is the clock used for feed timestamps and trending cutoffs. Tests
+// replace it to render feeds deterministically.
+var Now = time.Now
+
+// Feed formats accepted by the format query parameter.
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+	FormatJSON = "json"
+)
+
+var feedContentTypes = map[string]string{
+	FormatRSS:  "application/rss+xml; charset=utf-8",
+	FormatAtom: "application/atom+xml; charset=utf-8",
+	FormatJSON: "application/feed+json; charset=utf-8",
+}
+
+// itemDescription sanitizes upstream HTML for a feed item description,
+// resolving relative links against page, the URL it was copied from.
+func itemDescription(content string, page string) string {
+	base, _ := url.Parse(page)
+	return sanitize.HTML(content, base)
+}
+
+// RenderFeed serializes feed as format ("rss" when empty) and returns the
+// body with its content type. Images linked from item descriptions become
+// enclosures (and media:content in RSS).
+func RenderFeed(feed *feeds.Feed, format string) (string, string, error) {
+	if format == "" {
+		format = FormatRSS
+	}
+	contentType, ok := feedContentTypes[format]
+	if !ok {
+		return "", "", fmt.Errorf("error: invalid format, must be one of: rss, atom, json")
+	}
+
+	images := attachImages(feed)
+	rewriteImages(feed)
+	var body string
+	var err error
+	switch format {
+	case FormatAtom:
+		body, err = feed.ToAtom()
+	case FormatJSON:
+		body, err = feed.ToJSON()
+	default:
+		body, err = feeds.ToXML(&mediaRss{Rss: &feeds.Rss{Feed: feed}, images: images})
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return body, contentType, nil
+}
+
+// WriteFeed renders feed in the format requested by the format query
+// parameter and writes it to w.
+func WriteFeed(w http.ResponseWriter, r *http.Request, feed *feeds.Feed) {
+	_, span := tracing.Start(r.Context(), "feed.render", attribute.Int("items", len(feed.Items)))
+	body, contentType, err := RenderFeed(feed, r.URL.Query().Get("format"))
+	tracing.End(span, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metrics.FeedItems.WithLabelValues(r.URL.Path).Observe(float64(len(feed.Items)))
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(body))
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxRegexLength bounds regex parameters such as title_regex. RE2 runs in
+// linear time, but a huge pattern still costs memory to compile.
+const maxRegexLength = 200
+
+// filterParams are accepted by every feed route.
+var filterParams = []Param{
+	{Name: "exclude", Type: ParamString, Description: "排除標題 (噗浪為內文) 含任一關鍵字的項目，以逗號分隔，不分大小寫"},
+	{Name: "include_tag", Type: ParamString, Description: "只保留這些 [標籤] 的文章，以逗號分隔，例如 閒聊,情報"},
+	{Name: "exclude_tag", Type: ParamString, Description: "排除這些 [標籤] 的文章，以逗號分隔，例如 公告,問卦"},
+	{Name: "exclude_author", Type: ParamString, Description: "排除這些作者 (PTT 帳號或噗浪名稱)，以逗號分隔"},
+	{Name: "title_regex", Type: ParamString, Format: FormatRegex, Description: "只保留標題符合此正規表示式 (RE2) 的項目"},
+	{Name: "min_pushes", Type: ParamInteger, Min: bound(0), Description: "只保留推文數至少為此值的 PTT 文章"},
+}
+
+// Filter drops feed items by title, [tag], author and push count. It is
+// parsed once per request by Validate and handed to the route's Build func;
+// the feed builders apply it to list entries before fetching their pages,
+// and before any limit, so a filtered feed still returns up to limit items.
+// A nil Filter keeps everything.
+type Filter struct {
+	Exclude        []string // 小寫
+	IncludeTags    []string
+	ExcludeTags    []string
+	ExcludeAuthors []string
+	TitleRegex     *regexp.Regexp
+	MinPushes      int
+}
+
+// filterEntry is what a Filter sees of an article or plurk.
+type filterEntry struct {
+	Title  string
+	Author string
+	Pushes int // 推文數；未知時為 -1，不套用 min_pushes
+}
+
+// FilterFromQuery reads the filter parameters of q. List parameters accept
+// comma-separated values and may be repeated. It returns nil when no filter
+// is set, and a ParamError for an invalid title_regex.
+func FilterFromQuery(q url.Values) (*Filter, error) {
+	f := &Filter{
+		Exclude:        listParam(q, "exclude"),
+		IncludeTags:    listParam(q, "include_tag"),
+		ExcludeTags:    listParam(q, "exclude_tag"),
+		ExcludeAuthors: listParam(q, "exclude_author"),
+	}
+	for i, keyword := range f.Exclude {
+		f.Exclude[i] = strings.ToLower(keyword)
+	}
+	if pattern := q.Get("title_regex"); pattern != "" {
+		if len(pattern) > maxRegexLength {
+			return nil, &ParamError{Param: "title_regex", Message: "must be at most " + strconv.Itoa(maxRegexLength) + " characters"}
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, &ParamError{Param: "title_regex", Message: "must be a valid regular expression: " + err.Error()}
+		}
+		f.TitleRegex = re
+	}
+	if v, err := strconv.Atoi(q.Get("min_pushes")); err == nil && v > 0 {
+		f.MinPushes = v
+	}
+
+	if len(f.Exclude) == 0 && len(f.IncludeTags) == 0 && len(f.ExcludeTags) == 0 &&
+		len(f.ExcludeAuthors) == 0 && f.TitleRegex == nil && f.MinPushes == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Match reports whether e passes the filter. Entries with unknown pushes
+// skip the push check, so list entries can be checked before their push
+// count is known and again afterwards.
+func (f *Filter) Match(e filterEntry) bool {
+	if f == nil {
+		return true
+	}
+	title := strings.ToLower(e.Title)
+	for _, keyword := range f.Exclude {
+		if strings.Contains(title, keyword) {
+			return false
+		}
+	}
+
+	tag := extractTagType(e.Title)
+	if len(f.IncludeTags) > 0 && !containsFold(f.IncludeTags, tag) {
+		return false
+	}
+	if tag != "" && containsFold(f.ExcludeTags, tag) {
+		return false
+	}
+
+	if e.Author != "" {
+		// PTT 文章頁的作者為「帳號 (暱稱)」，列表只有帳號
+		id, _, _ := strings.Cut(e.Author, " (")
+		if containsFold(f.ExcludeAuthors, e.Author) || containsFold(f.ExcludeAuthors, id) {
+			return false
+		}
+	}
+
+	if f.TitleRegex != nil && !f.TitleRegex.MatchString(e.Title) {
+		return false
+	}
+	if e.Pushes >= 0 && e.Pushes < f.MinPushes {
+		return false
+	}
+	return true
+}
+
+type filterKey struct{}
+
+// withFilter attaches the filter parsed from the query of r.
+func withFilter(r *http.Request, f *Filter) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), filterKey{}, f))
+}
+
+// requestFilter returns the filter Validate parsed for r, or parses it when
+// r did not go through Validate.
+func requestFilter(r *http.Request) (*Filter, error) {
+	if f, ok := r.Context().Value(filterKey{}).(*Filter); ok {
+		return f, nil
+	}
+	return FilterFromQuery(r.URL.Query())
+}
+
+// listParam returns the comma-separated values of every name parameter.
+func listParam(q url.Values, name string) []string {
+	var values []string
+	for _, value := range q[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
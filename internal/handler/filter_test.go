@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestFilterFromQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantNil bool
+		wantErr bool
+	}{
+		{"no filter", "board=C_Chat&limit=5", true, false},
+		{"empty values", "exclude=&include_tag=%20,", true, false},
+		{"exclude", "exclude=公告", false, false},
+		{"min pushes", "min_pushes=10", false, false},
+		{"bad regex", "title_regex=(", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			f, err := FilterFromQuery(q)
+			if tt.wantErr {
+				var paramErr *ParamError
+				if !errors.As(err, &paramErr) || paramErr.Param != "title_regex" {
+					t.Fatalf("err = %v, want title_regex ParamError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (f == nil) != tt.wantNil {
+				t.Errorf("filter = %+v, want nil = %v", f, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		entry filterEntry
+		want  bool
+	}{
+		{"no filter", "", filterEntry{Title: "[公告] 板規", Pushes: -1}, true},
+		{"exclude keyword", "exclude=板規,置底", filterEntry{Title: "[公告] 板規修訂", Pushes: -1}, false},
+		{"exclude ignores case", "exclude=VTUBER", filterEntry{Title: "[閒聊] vtuber 直播", Pushes: -1}, false},
+		{"exclude other", "exclude=板規", filterEntry{Title: "[閒聊] 芙莉蓮", Pushes: -1}, true},
+		{"include tag", "include_tag=閒聊,情報", filterEntry{Title: "Re: [情報] 新番", Pushes: -1}, true},
+		{"include tag other", "include_tag=閒聊,情報", filterEntry{Title: "[問題] 求推薦", Pushes: -1}, false},
+		{"include tag untagged", "include_tag=閒聊", filterEntry{Title: "沒有標籤", Pushes: -1}, false},
+		{"exclude tag", "exclude_tag=問卦&exclude_tag=公告", filterEntry{Title: "[公告] 板規", Pushes: -1}, false},
+		{"exclude tag untagged", "exclude_tag=問卦", filterEntry{Title: "沒有標籤", Pushes: -1}, true},
+		{"exclude author id", "exclude_author=Troll", filterEntry{Title: "[閒聊] x", Author: "troll (小白)", Pushes: -1}, false},
+		{"exclude author list", "exclude_author=troll", filterEntry{Title: "[閒聊] x", Author: "troll", Pushes: -1}, false},
+		{"exclude author other", "exclude_author=troll", filterEntry{Title: "[閒聊] x", Author: "trollhunter (獵人)", Pushes: -1}, true},
+		{"exclude plurk name", "exclude_author=王小明", filterEntry{Title: "今天", Author: "王小明", Pushes: -1}, false},
+		{"title regex", "title_regex=第[0-9]%2B集", filterEntry{Title: "[閒聊] 芙莉蓮 第3集", Pushes: -1}, true},
+		{"title regex miss", "title_regex=第[0-9]%2B集", filterEntry{Title: "[閒聊] 芙莉蓮 好看", Pushes: -1}, false},
+		{"min pushes", "min_pushes=50", filterEntry{Title: "[閒聊] x", Pushes: 103}, true},
+		{"min pushes below", "min_pushes=50", filterEntry{Title: "[閒聊] x", Pushes: 12}, false},
+		{"min pushes unknown", "min_pushes=50", filterEntry{Title: "[閒聊] x", Pushes: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			f, err := FilterFromQuery(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Match(tt.entry); got != tt.want {
+				t.Errorf("Match(%+v) = %v, want %v", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,39 @@
 package handler
 
 import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/tracing"
 )
 
 func init() {
-	functions.HTTP("GetPlurkSearch", GetPlurkSearch)
-	functions.HTTP("GetPlurkTop", GetPlurkTop)
-	functions.HTTP("GetPttSearch", GetPttSearch)
+	for _, route := range Routes {
+		functions.HTTP(route.Function, instrument(route.Function, Mount(route)))
+	}
+}
+
+// setupFunction installs the logger and tracer of the Cloud Functions
+// runtime. It runs on the first invocation rather than in init, so other
+// importers of the package (the server, cmd/signurl, tests) keep their own.
+var setupFunction = sync.OnceFunc(func() {
+	slog.SetDefault(logging.New(os.Stderr, current.Log.Level, current.Log.Format))
+	if _, err := tracing.Setup(context.Background(), current.Tracing); err != nil {
+		slog.Warn("tracing 設定失敗", "err", err)
+	}
+})
+
+// instrument wraps a Cloud Functions handler with a server span and the
+// request log.
+func instrument(name string, h http.HandlerFunc) http.HandlerFunc {
+	wrapped := tracing.Middleware(name, logging.Middleware(h))
+	return func(w http.ResponseWriter, r *http.Request) {
+		setupFunction()
+		wrapped.ServeHTTP(w, r)
+	}
 }
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/imgproxy"
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/sanitize"
+	"github.com/gorilla/feeds"
+)
+
+// imageMaxAge is how long readers may cache /img responses. The response of
+// a signed URL never changes, so it is long.
+const imageMaxAge = 7 * 24 * time.Hour
+
+// Cloud Functions handler
+func GetImage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	src := q.Get("url")
+	width, _ := strconv.Atoi(q.Get("w"))
+
+	img, hit := images.Get(imgproxy.Key(src, width))
+	metrics.ObserveCache("image", hit)
+	if !hit {
+		var err error
+		if img, err = fetchImage(r, src, width); err != nil {
+			logging.FromRequest(r).Warn("圖片抓取失敗", "url", src, "width", width, "err", err)
+			writeJSONError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(img.Data)))
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(imageMaxAge.Seconds()))+", immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Write(img.Data)
+}
+
+// fetchImage downloads src, resizes it when width is set and caches the
+// result. A cache write failure only costs a refetch, so it is logged.
+func fetchImage(r *http.Request, src string, width int) (imgproxy.Image, error) {
+	img, err := imgproxy.Fetch(r.Context(), newImageClient(r), src, current.ImageProxy.MaxBytes, current.Upstream.UserAgent)
+	if err != nil {
+		return img, err
+	}
+	if width > 0 {
+		if img, err = imgproxy.Thumbnail(img, width); err != nil {
+			return img, err
+		}
+	}
+	if err := images.Put(imgproxy.Key(src, width), img); err != nil {
+		logging.FromRequest(r).Warn("圖片快取寫入失敗", "dir", images.Dir(), "err", err)
+	}
+	return img, nil
+}
+
+// proxyImage returns the signed /img URL of src when image_proxy.rewrite is
+// set, and src otherwise. The signature never expires: feed readers keep old
+// items around, and rotating auth.signing_secrets revokes them all.
+func proxyImage(src string) string {
+	cfg := current.ImageProxy
+	if !cfg.Rewrite || signer == nil || strings.HasPrefix(src, cfg.PublicURL+"/") {
+		return src
+	}
+	q := url.Values{"url": {src}}
+	if cfg.ThumbnailWidth > 0 {
+		q.Set("w", strconv.Itoa(cfg.ThumbnailWidth))
+	}
+	// 簽章只涵蓋 /img；public_url 若有路徑前綴，需由反向代理去除
+	signed := signer.Sign(&url.URL{Path: "/img", RawQuery: q.Encode()}, time.Time{})
+	return cfg.PublicURL + signed.String()
+}
+
+// rewriteImages points the images in the descriptions and enclosures of feed
+// at /img. It runs after attachImages, which needs the original URLs.
+func rewriteImages(feed *feeds.Feed) {
+	if !current.ImageProxy.Rewrite {
+		return
+	}
+	for _, item := range feed.Items {
+		item.Description = sanitize.RewriteImages(item.Description, proxyImage)
+		if item.Enclosure != nil && strings.HasPrefix(item.Enclosure.Type, "image/") {
+			item.Enclosure.Url = proxyImage(item.Enclosure.Url)
+		}
+	}
+}
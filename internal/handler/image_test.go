@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/gorilla/feeds"
+)
+
+const testPublicURL = "https://feed.example.com"
+
+func configureImageProxy(t *testing.T, allowPrivate bool) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth.SigningSecrets = []string{"signing-secret-0123456789"}
+	cfg.ImageProxy.CacheDir = t.TempDir()
+	cfg.ImageProxy.Rewrite = true
+	cfg.ImageProxy.PublicURL = testPublicURL
+	cfg.ImageProxy.ThumbnailWidth = 100
+	cfg.ImageProxy.AllowPrivate = allowPrivate
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetImage(t *testing.T) {
+	original := current
+	defer Configure(original)
+	configureImageProxy(t, true)
+
+	var buf bytes.Buffer
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	src.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	png.Encode(&buf, src)
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(buf.Bytes())
+	}))
+	defer upstream.Close()
+
+	var route Route
+	for _, rt := range Routes {
+		if rt.Path == "/img" {
+			route = rt
+		}
+	}
+	h := Mount(route)
+
+	proxied := strings.TrimPrefix(proxyImage(upstream.URL+"/a.png"), testPublicURL)
+	tampered := strings.Replace(proxied, "w=100", "w=200", 1)
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{"signed", proxied, 200},
+		{"cached", proxied, 200},
+		{"unsigned", "/img?url=" + url.QueryEscape(upstream.URL+"/a.png"), 403},
+		{"tampered width", tampered, 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest("GET", tt.url, nil))
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+				t.Errorf("Content-Type = %s", ct)
+			}
+			cfg, _, err := image.DecodeConfig(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != 100 || cfg.Height != 50 {
+				t.Errorf("thumbnail = %dx%d, want 100x50", cfg.Width, cfg.Height)
+			}
+		})
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("upstream fetched %d times, want 1 (second request from cache)", n)
+	}
+}
+
+// 預設拒絕抓取內網位址，避免文章內的圖片網址被用來探測內網
+func TestGetImageRefusesPrivateAddress(t *testing.T) {
+	original := current
+	defer Configure(original)
+	configureImageProxy(t, false)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer upstream.Close()
+
+	w := httptest.NewRecorder()
+	GetImage(w, httptest.NewRequest("GET", "/img?url="+url.QueryEscape(upstream.URL+"/a.png"), nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRenderFeedRewritesImages(t *testing.T) {
+	original := current
+	defer Configure(original)
+	configureImageProxy(t, true)
+
+	feed := &feeds.Feed{
+		Title: "test",
+		Link:  &feeds.Link{Href: "https://www.ptt.cc/bbs/C_Chat/index.html"},
+		Items: []*feeds.Item{{
+			Title:       "有圖",
+			Link:        &feeds.Link{Href: "https://www.ptt.cc/bbs/C_Chat/M.1.A.html"},
+			Description: `<a href="https://imgur.com/abc123" rel="nofollow noopener noreferrer">https://imgur.com/abc123</a><img src="https://images.plurk.com/xyz.png">`,
+		}},
+	}
+	body, _, err := RenderFeed(feed, FormatRSS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		// 連結維持原樣，圖片改寫
+		`href=&#34;https://imgur.com/abc123&#34;`,
+		`src=&#34;` + testPublicURL + "/img?sig=",
+		`<enclosure url="` + testPublicURL + "/img?sig=",
+		`<media:content url="` + testPublicURL + "/img?sig=",
+		url.QueryEscape("https://i.imgur.com/abc123.jpg"),
+		url.QueryEscape("https://images.plurk.com/xyz.png"),
+		`type="image/png"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed does not contain %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `url="https://i.imgur.com`) {
+		t.Errorf("enclosure or media:content not rewritten:\n%s", body)
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/xml"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/media"
+	"github.com/gorilla/feeds"
+)
+
+// mediaNamespace is the Media RSS namespace of media:content.
+const mediaNamespace = "http://search.yahoo.com/mrss/"
+
+// attachImages finds the images of every item in its description and sets
+// the first one as the item's enclosure, which gorilla/feeds renders as the
+// RSS enclosure, the Atom enclosure link and the JSON Feed image. It returns
+// all images per item for media:content.
+func attachImages(feed *feeds.Feed) [][]string {
+	images := make([][]string, len(feed.Items))
+	for i, item := range feed.Items {
+		images[i] = media.Images(item.Description)
+		if len(images[i]) > 0 && item.Enclosure == nil {
+			// 長度未知；RSS 規格要求此屬性，慣例上填 0
+			item.Enclosure = &feeds.Enclosure{Url: images[i][0], Type: media.Type(images[i][0]), Length: "0"}
+		}
+	}
+	return images
+}
+
+// mediaRss renders an RSS feed with a media:content element per image.
+type mediaRss struct {
+	*feeds.Rss
+	images [][]string
+}
+
+type mediaRssXml struct {
+	XMLName          xml.Name `xml:"rss"`
+	Version          string   `xml:"version,attr"`
+	ContentNamespace string   `xml:"xmlns:content,attr"`
+	MediaNamespace   string   `xml:"xmlns:media,attr"`
+	Channel          *mediaChannel
+}
+
+// mediaChannel replaces the items of the embedded channel.
+type mediaChannel struct {
+	*feeds.RssFeed
+	Items []*mediaItem `xml:"item"`
+}
+
+type mediaItem struct {
+	*feeds.RssItem
+	Media []mediaContent
+}
+
+type mediaContent struct {
+	XMLName xml.Name `xml:"media:content"`
+	URL     string   `xml:"url,attr"`
+	Type    string   `xml:"type,attr"`
+	Medium  string   `xml:"medium,attr"`
+}
+
+// FeedXml implements feeds.XmlFeed.
+func (m *mediaRss) FeedXml() interface{} {
+	channel := &mediaChannel{RssFeed: m.RssFeed()}
+	for i, item := range channel.RssFeed.Items {
+		mi := &mediaItem{RssItem: item}
+		for _, image := range m.images[i] {
+			mi.Media = append(mi.Media, mediaContent{URL: proxyImage(image), Type: media.Type(image), Medium: "image"})
+		}
+		channel.Items = append(channel.Items, mi)
+	}
+	return &mediaRssXml{
+		Version:          "2.0",
+		ContentNamespace: "http://purl.org/rss/1.0/modules/content/",
+		MediaNamespace:   mediaNamespace,
+		Channel:          channel,
+	}
+}
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Param describes one query parameter of a route. The same description
+// generates the OpenAPI spec (internal/openapi) and drives Validate, so the
+// documentation cannot drift from what the handlers accept.
+type Param struct {
+	Name        string
+	In          string // "query" (default) or "path" for {name} in Route.Path
+	Type        string // "string", "integer", "number" or "duration" (Go duration, e.g. 90m)
+	Description string
+	Required    bool
+	Default     string
+	Enum        []string
+	Min, Max    *float64 // inclusive bounds for integer, number and duration (in seconds)
+	Pattern     string
+	Format      string // "regex": an RE2 expression, compiled by FilterFromQuery
+}
+
+// InPath marks path parameters such as the id of /f/{id}. Validate skips
+// them; the handler looks them up.
+const InPath = "path"
+
+// FormatRegex marks string parameters holding a regular expression.
+const FormatRegex = "regex"
+
+// Parameter types.
+const (
+	ParamString   = "string"
+	ParamInteger  = "integer"
+	ParamNumber   = "number"
+	ParamDuration = "duration"
+)
+
+// bound returns a pointer for Param.Min and Param.Max.
+func bound(v float64) *float64 {
+	return &v
+}
+
+// formatParam is accepted by every feed route.
+var formatParam = Param{
+	Name:        "format",
+	Type:        ParamString,
+	Description: "輸出格式",
+	Default:     FormatRSS,
+	Enum:        []string{FormatRSS, FormatAtom, FormatJSON},
+}
+
+// boardPattern matches PTT board names such as C_Chat or Hearthstone.
+const boardPattern = `^[A-Za-z0-9_-]{1,20}$`
+
+// FieldError is one invalid query parameter.
+type FieldError struct {
+	Param   string `json:"param"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError is the JSON body of every 400 response.
+type ValidationError struct {
+	Error  string       `json:"error"`
+	Errors []FieldError `json:"errors"`
+}
+
+// AllParams returns the route parameters, including format and the filter
+// parameters for feed routes.
+func (rt Route) AllParams() []Param {
+	if !rt.Feed {
+		return rt.Params
+	}
+	params := append(append([]Param(nil), rt.Params...), formatParam)
+	return append(params, filterParams...)
+}
+
+// Validate wraps the route handler with query validation: requests that do
+// not match rt's parameters get a structured 400 and never reach the handler.
+// Unknown parameters are ignored. The filter of a feed route is parsed here,
+// once per request, and passed on to the handler with the request.
+func Validate(rt Route) http.HandlerFunc {
+	params := rt.AllParams()
+	patterns := compilePatterns(params)
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, errs := rt.checkRoute(params, patterns, r.URL.Query())
+		if len(errs) > 0 {
+			writeValidationError(w, errs)
+			return
+		}
+		if rt.Feed {
+			r = withFilter(r, filter)
+		}
+		rt.Handler(w, r)
+	}
+}
+
+// CheckParams validates q against rt's parameters without serving it, e.g.
+// before signing a feed URL.
+func (rt Route) CheckParams(q url.Values) []FieldError {
+	_, errs := rt.parseParams(q)
+	return errs
+}
+
+// parseParams validates q against rt's parameters and returns the filter
+// of a feed route.
+func (rt Route) parseParams(q url.Values) (*Filter, []FieldError) {
+	params := rt.AllParams()
+	return rt.checkRoute(params, compilePatterns(params), q)
+}
+
+// checkRoute checks q against params and, for feed routes, parses the
+// filter parameters; title_regex is only compiled here.
+func (rt Route) checkRoute(params []Param, patterns map[string]*regexp.Regexp, q url.Values) (*Filter, []FieldError) {
+	errs := checkParams(params, patterns, q)
+	if !rt.Feed {
+		return nil, errs
+	}
+	filter, err := FilterFromQuery(q)
+	var paramErr *ParamError
+	if errors.As(err, &paramErr) {
+		errs = append(errs, FieldError{Param: paramErr.Param, Value: q.Get(paramErr.Param), Message: paramErr.Message})
+	}
+	return filter, errs
+}
+
+func compilePatterns(params []Param) map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp)
+	for _, p := range params {
+		if p.Pattern != "" {
+			patterns[p.Name] = regexp.MustCompile(p.Pattern)
+		}
+	}
+	return patterns
+}
+
+func checkParams(params []Param, patterns map[string]*regexp.Regexp, q url.Values) []FieldError {
+	var errs []FieldError
+	for _, p := range params {
+		if p.In == InPath {
+			continue
+		}
+		value := q.Get(p.Name)
+		if value == "" {
+			if p.Required {
+				errs = append(errs, FieldError{Param: p.Name, Message: "is required"})
+			}
+			continue
+		}
+		if msg := p.check(value, patterns[p.Name]); msg != "" {
+			errs = append(errs, FieldError{Param: p.Name, Value: value, Message: msg})
+		}
+	}
+	return errs
+}
+
+// check returns why value does not satisfy p, or "" when it does.
+func (p Param) check(value string, pattern *regexp.Regexp) string {
+	if len(p.Enum) > 0 {
+		for _, e := range p.Enum {
+			if value == e {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(p.Enum, ", ")
+	}
+	if pattern != nil && !pattern.MatchString(value) {
+		return "must match " + p.Pattern
+	}
+
+	var n float64
+	switch p.Type {
+	case ParamInteger:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return "must be an integer"
+		}
+		n = float64(i)
+	case ParamNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		// ParseFloat 接受 NaN 與 Inf，NaN 與任何界限比較皆為 false
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "must be a number"
+		}
+		n = f
+	case ParamDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return "must be a duration such as 90m or 2h"
+		}
+		n = d.Seconds()
+	default:
+		return ""
+	}
+	if p.Min != nil && n < *p.Min {
+		return "must be at least " + p.formatBound(*p.Min)
+	}
+	if p.Max != nil && n > *p.Max {
+		return "must be at most " + p.formatBound(*p.Max)
+	}
+	return ""
+}
+
+func (p Param) formatBound(v float64) string {
+	if p.Type == ParamDuration {
+		return (time.Duration(v) * time.Second).String()
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeValidationError(w http.ResponseWriter, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ValidationError{
+		Error:  fmt.Sprintf("invalid request: %d invalid parameter(s)", len(errs)),
+		Errors: errs,
+	})
+}
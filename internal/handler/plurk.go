@@ -1,16 +1,22 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/tracing"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Plurk struct {
@@ -36,25 +42,110 @@ type StatEntry struct {
 	Stats Stats `json:"stats"`
 }
 
-// Cloud Functions handlers
+// plurkOrigin is the canonical Plurk origin used in feed links.
+const plurkOrigin = "https://www.plurk.com"
+
+// PlurkClient fetches Plurk search and Stats results and renders them as RSS.
+type PlurkClient struct {
+	HttpClient *http.Client
+	// BaseURL is where API requests are sent, e.g. a local fixture server in
+	// tests. Feed links keep pointing at plurkOrigin.
+	BaseURL   string
+	UserAgent string
+	// Timeout bounds each Plurk request on top of HttpClient's own timeout;
+	// zero means no extra limit.
+	Timeout time.Duration
+	// Logger receives parse warnings; nil means slog.Default().
+	Logger *slog.Logger
+	// Filter drops plurks from the feeds; nil keeps everything. Plurks have
+	// no push count, so min_pushes does not apply.
+	Filter *Filter
+
+	ctx context.Context // inbound request context; parent of upstream requests and spans
+}
+
+// NewPlurkClient returns a client using the upstream settings from config.
+func NewPlurkClient(client *http.Client) *PlurkClient {
+	return &PlurkClient{
+		HttpClient: client,
+		BaseURL:    current.Upstream.PlurkBaseURL,
+		UserAgent:  current.Upstream.UserAgent,
+		Timeout:    current.Upstream.Timeout,
+	}
+}
+
+// NewPlurkClientForRequest returns a client whose upstream requests, logs
+// and spans belong to r: they carry its request ID and trace, and are
+// canceled with it.
+func NewPlurkClientForRequest(r *http.Request) *PlurkClient {
+	c := NewPlurkClient(NewUpstreamClient(r))
+	c.Logger = logging.FromRequest(r)
+	c.ctx = r.Context()
+	return c
+}
+
+func (c *PlurkClient) context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+func (c *PlurkClient) log() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
+
+// do sends req with the client's user agent and timeout. The returned cancel
+// func must be called once the body has been read.
+func (c *PlurkClient) do(req *http.Request) (*http.Response, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+	if c.Timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), c.Timeout)
+		req = req.WithContext(ctx)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return resp, cancel, nil
+}
+
+// GetPlurkSearch handles GET /plurk/search?keyword=台灣
 func GetPlurkSearch(w http.ResponseWriter, r *http.Request) {
-	keyword := r.URL.Query().Get("keyword")
-	rss, err := ProcessPlurkSearch(keyword)
+	serveFeed(w, r, buildPlurkSearch)
+}
+
+func buildPlurkSearch(r *http.Request, filter *Filter) (*feeds.Feed, error) {
+	keyword, err := requiredParam(r.URL.Query(), "keyword", "search keyword cannot be empty")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-	w.Write([]byte(rss))
+	c := NewPlurkClientForRequest(r)
+	c.Filter = filter
+	return c.SearchFeed(keyword)
 }
 
+// GetPlurkTop handles GET /plurk/top?qType=topResponded
 func GetPlurkTop(w http.ResponseWriter, r *http.Request) {
+	serveFeed(w, r, buildPlurkTop)
+}
+
+func buildPlurkTop(r *http.Request, filter *Filter) (*feeds.Feed, error) {
 	qType := r.URL.Query().Get("qType")
-	rss, err := ProcessPlurkTop(qType)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if err := oneOf("qType", qType, "topResponded", "hot", "favorite"); err != nil {
+		return nil, err
 	}
-	w.Write([]byte(rss))
+	c := NewPlurkClientForRequest(r)
+	c.Filter = filter
+	return c.TopFeed(qType)
 }
 
 func trimTitleFromContent(textContent string) string {
@@ -66,46 +157,80 @@ func trimTitleFromContent(textContent string) string {
 	return title
 }
 
+// ProcessPlurkSearch renders Plurk search results using the default client.
 func ProcessPlurkSearch(keyword string) (string, error) {
+	return NewPlurkClient(NewUpstreamClient(nil)).Search(keyword)
+}
+
+// ProcessPlurkTop renders a Plurk Stats list using the default client.
+func ProcessPlurkTop(qType string) (string, error) {
+	return NewPlurkClient(NewUpstreamClient(nil)).Top(qType)
+}
+
+// Search renders Plurk search results for keyword as RSS.
+func (c *PlurkClient) Search(keyword string) (string, error) {
+	feed, err := c.SearchFeed(keyword)
+	if err != nil {
+		return "", err
+	}
+	return feed.ToRss()
+}
+
+// SearchFeed returns Plurk search results for keyword as a feed.
+func (c *PlurkClient) SearchFeed(keyword string) (*feeds.Feed, error) {
 	if keyword == "" {
-		return "", fmt.Errorf("error: search keyword cannot be empty")
+		return nil, fmt.Errorf("error: search keyword cannot be empty")
 	}
-	urlStr := "https://www.plurk.com/Search/search2"
+	urlStr := plurkOrigin + "/Search/search2"
 	feed := &feeds.Feed{
 		Title:       "Plurk Search - " + keyword,
 		Link:        &feeds.Link{Href: urlStr},
 		Description: "Search results from Plurk",
 		Author:      &feeds.Author{Name: "Feed Generator"},
-		Created:     time.Now(),
+		Created:     Now(),
 	}
 
-	resp, err := http.PostForm(urlStr, url.Values{"query": {keyword}})
+	form := url.Values{"query": {keyword}}
+	req, err := http.NewRequestWithContext(c.context(), "POST", c.BaseURL+"/Search/search2", strings.NewReader(form.Encode()))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, cancel, err := c.do(req)
+	if err != nil {
+		return nil, err
 	}
+	defer cancel()
 	defer resp.Body.Close()
 
+	_, span := tracing.Start(c.context(), "plurk.parse", attribute.String("endpoint", "search"))
+	defer span.End()
+
 	var body struct {
 		Plurks []Plurk `json:"plurks"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	for _, p := range body.Plurks {
 		doc, err := goquery.NewDocumentFromReader(strings.NewReader(p.Content))
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		textContent := doc.Text()
+		if !c.Filter.Match(filterEntry{Title: textContent, Pushes: -1}) {
+			continue
+		}
 		title := trimTitleFromContent(textContent)
 
-		url := "https://www.plurk.com/p/" + strconv.FormatInt(int64(p.ID), 36)
+		url := plurkOrigin + "/p/" + strconv.FormatInt(int64(p.ID), 36)
 
 		// 修正時間解析，使用GMT格式
 		posted, err := time.Parse("Mon, 02 Jan 2006 15:04:05 GMT", p.Posted)
 		if err != nil {
-			fmt.Printf("時間解析錯誤: %v, 原始時間字串: %s\n", err, p.Posted)
+			c.log().Warn("時間解析錯誤", "posted", p.Posted, "err", err)
+			metrics.ParseFailures.WithLabelValues("plurk_search").Inc()
 			continue
 		}
 
@@ -119,45 +244,60 @@ func ProcessPlurkSearch(keyword string) (string, error) {
 			&feeds.Item{
 				Title:       title,
 				Link:        &feeds.Link{Href: url},
-				Description: desc,
+				Description: itemDescription(desc, url),
 				Created:     postedTPE, // 使用台北時間
 			},
 		)
 	}
 
-	rss, err := feed.ToRss()
+	return feed, nil
+}
+
+// Top renders the Plurk Stats list qType ("topResponded", "hot" or
+// "favorite") as RSS.
+func (c *PlurkClient) Top(qType string) (string, error) {
+	feed, err := c.TopFeed(qType)
 	if err != nil {
 		return "", err
 	}
-	return rss, nil
+	return feed.ToRss()
 }
 
-func ProcessPlurkTop(qType string) (string, error) {
+// TopFeed returns the Plurk Stats list qType as a feed.
+func (c *PlurkClient) TopFeed(qType string) (*feeds.Feed, error) {
 	if qType != "topResponded" && qType != "hot" && qType != "favorite" {
-		return "", fmt.Errorf("error: invalid qType, must be one of: topResponded, hot, favorite")
+		return nil, fmt.Errorf("error: invalid qType, must be one of: topResponded, hot, favorite")
 	}
-	url := "https://www.plurk.com/Stats/" + qType + "?period=day&lang=zh&limit=15"
-	println(qType)
-	println(url)
+	path := "/Stats/" + qType + "?period=day&lang=zh&limit=15"
+	url := plurkOrigin + path
+	c.log().Debug("Plurk 熱門", "qType", qType, "url", url)
 	feed := &feeds.Feed{
 		Title:       "Plurk Top",
 		Link:        &feeds.Link{Href: url},
 		Description: "Top replurks from Plurk",
 		Author:      &feeds.Author{Name: "Feed Generator"},
-		Created:     time.Now(),
+		Created:     Now(),
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(c.context(), "GET", c.BaseURL+path, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	resp, cancel, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
 	defer resp.Body.Close()
 
+	_, span := tracing.Start(c.context(), "plurk.parse", attribute.String("endpoint", qType))
+	defer span.End()
+
 	var body struct {
 		Stats [][]interface{} `json:"stats"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	for _, statArray := range body.Stats {
@@ -177,12 +317,13 @@ func ProcessPlurkTop(qType string) (string, error) {
 			continue
 		}
 
-		url := "https://www.plurk.com/p/" + strconv.FormatInt(int64(stat.PlurkID), 36)
+		url := plurkOrigin + "/p/" + strconv.FormatInt(int64(stat.PlurkID), 36)
 
 		// 修正時間解析
 		posted, err := time.Parse("Mon, 02 Jan 2006 15:04:05 GMT", stat.Posted)
 		if err != nil {
-			fmt.Printf("時間解析錯誤: %v, 原始時間字串: %s\n", err, stat.Posted)
+			c.log().Warn("時間解析錯誤", "posted", stat.Posted, "err", err)
+			metrics.ParseFailures.WithLabelValues("plurk_top").Inc()
 			continue
 		}
 
@@ -190,6 +331,10 @@ func ProcessPlurkTop(qType string) (string, error) {
 		taipeiLoc, _ := time.LoadLocation("Asia/Taipei")
 		postedTPE := posted.In(taipeiLoc)
 
+		if !c.Filter.Match(filterEntry{Title: stat.ContentRaw, Author: stat.Owner.FullName, Pushes: -1}) {
+			continue
+		}
+
 		content := stat.Content
 		title := stat.ContentRaw
 		title = trimTitleFromContent(title)
@@ -198,16 +343,12 @@ func ProcessPlurkTop(qType string) (string, error) {
 			&feeds.Item{
 				Title:       title,
 				Link:        &feeds.Link{Href: url},
-				Description: content,
+				Description: itemDescription(content, url),
 				Author:      &feeds.Author{Name: stat.Owner.FullName},
 				Created:     postedTPE, // 使用台北時間
 			},
 		)
 	}
 
-	rss, err := feed.ToRss()
-	if err != nil {
-		return "", err
-	}
-	return rss, nil
+	return feed, nil
 }
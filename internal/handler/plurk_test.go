@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlurkClientRequest(t *testing.T) {
+	var gotAgent, gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAgent = r.Header.Get("User-Agent")
+		r.ParseForm()
+		gotQuery = r.PostForm.Get("query")
+		w.Write([]byte(`{"plurks":[{"id":36,"content":"hello","posted":"Thu, 22 Jan 2026 11:30:00 GMT"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewPlurkClient(server.Client())
+	client.BaseURL = server.URL
+	client.UserAgent = "feed-test"
+
+	rss, err := client.Search("台灣")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if gotAgent != "feed-test" || gotQuery != "台灣" {
+		t.Errorf("request user agent/query = %q/%q", gotAgent, gotQuery)
+	}
+	if !strings.Contains(rss, "https://www.plurk.com/p/10") {
+		t.Errorf("feed should link to plurk.com, got:\n%s", rss)
+	}
+}
+
+func TestPlurkClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewPlurkClient(server.Client())
+	client.BaseURL = server.URL
+	client.Timeout = 20 * time.Millisecond
+
+	if _, err := client.Top("hot"); err == nil {
+		t.Fatal("Top() error = nil, want timeout")
+	}
+}
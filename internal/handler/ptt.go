@@ -1,19 +1,41 @@
 package handler
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/tracing"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// pttOrigin is the canonical PTT web origin used in article URLs and feed links.
+const pttOrigin = "https://www.ptt.cc"
+
+// articleMetaLines are the author/board/title/time header lines of an
+// article page.
+const articleMetaLines = "div.article-metaline, div.article-metaline-right"
+
 type PttParser struct {
 	HttpClient *http.Client
+	// BaseURL is where requests for pttOrigin URLs are sent, e.g. a local
+	// fixture server in tests. Feed links keep pointing at pttOrigin.
+	BaseURL string
+	// Logger receives fetch and parse warnings; nil means slog.Default().
+	Logger *slog.Logger
+	// Filter drops articles from the feeds; nil keeps everything.
+	Filter *Filter
+
+	ctx context.Context // inbound request context; parent of upstream requests and spans
 }
 
 type Article struct {
@@ -23,32 +45,66 @@ type Article struct {
 }
 
 func NewPttParser(client *http.Client) *PttParser {
-	return &PttParser{HttpClient: client}
+	return &PttParser{HttpClient: client, BaseURL: current.Upstream.PttBaseURL}
+}
+
+// NewPttParserForRequest returns a parser whose upstream requests, logs and
+// spans belong to r: they carry its request ID and trace, and are canceled
+// with it.
+func NewPttParserForRequest(r *http.Request) *PttParser {
+	p := NewPttParser(NewUpstreamClient(r))
+	p.Logger = logging.FromRequest(r)
+	p.ctx = r.Context()
+	return p
+}
+
+func (p *PttParser) context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
+	}
+	return context.Background()
+}
+
+func (p *PttParser) log() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
 }
 
-// Cloud Functions handler
+// GetPttSearch handles GET /ptt/search?board=C_Chat&keyword=閒聊&page=1&pages=1
 func GetPttSearch(w http.ResponseWriter, r *http.Request) {
-	parser := NewPttParser(&http.Client{Timeout: 15 * time.Second})
-	keyword := r.URL.Query().Get("keyword")
-	board := r.URL.Query().Get("board")
-	page := parsePositiveInt(r.URL.Query().Get("page"), 1, 1, 1000)
-	pages := parsePositiveInt(r.URL.Query().Get("pages"), 1, 1, 5)
-	rss, err := parser.FetchArticlesPaged(board, keyword, page, pages)
+	serveFeed(w, r, buildPttSearch)
+}
+
+func buildPttSearch(r *http.Request, filter *Filter) (*feeds.Feed, error) {
+	opts, err := SearchOptionsFromQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
-	w.Write([]byte(rss))
+	p := NewPttParserForRequest(r)
+	p.Filter = filter
+	return p.BuildSearchFeed(opts.Board, opts.Keyword, opts.Page, opts.Pages)
 }
 
 func (p *PttParser) FetchArticles(board string, keyword string) (string, error) {
 	return p.FetchArticlesPaged(board, keyword, 1, 1)
 }
 
+// FetchArticlesPaged renders the search results as RSS.
 func (p *PttParser) FetchArticlesPaged(board string, keyword string, page int, pages int) (string, error) {
+	feed, err := p.BuildSearchFeed(board, keyword, page, pages)
+	if err != nil {
+		return "", err
+	}
+	return feed.ToRss()
+}
+
+// BuildSearchFeed fetches pages search result pages starting at page and
+// returns them as a feed.
+func (p *PttParser) BuildSearchFeed(board string, keyword string, page int, pages int) (*feeds.Feed, error) {
 	if board == "" {
-		return "", fmt.Errorf("error: board name cannot be empty")
+		return nil, fmt.Errorf("error: board name cannot be empty")
 	}
 	page = clampInt(page, 1, 1000)
 	pages = clampInt(pages, 1, 5)
@@ -57,7 +113,7 @@ func (p *PttParser) FetchArticlesPaged(board string, keyword string, page int, p
 	for currentPage := page; currentPage < page+pages; currentPage++ {
 		pageArticles, err := p.fetchSearchResultPage(board, keyword, currentPage)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		articles = append(articles, pageArticles...)
 	}
@@ -69,34 +125,29 @@ func (p *PttParser) FetchArticlesPaged(board string, keyword string, page int, p
 		Link:        &feeds.Link{Href: searchUrl},
 		Description: fmt.Sprintf("Search results from PTT %s for %s", board, keyword),
 		Author:      &feeds.Author{Name: "Feed Generator"},
-		Created:     time.Now(),
+		Created:     Now(),
 	}
 
 	for _, article := range articles {
 		if err := p.addArticleToFeed(feed, article); err != nil {
-			fmt.Printf("略過文章: %s, 網址: %s, 錯誤: %v\n", article.Title, article.Url, err)
+			p.log().Warn("略過文章", "title", article.Title, "url", article.Url, "err", err)
+			metrics.ParseFailures.WithLabelValues("ptt_search").Inc()
 			continue
 		}
 	}
-
-	rss, err := feed.ToRss()
-	if err != nil {
-		return "", err
-	}
-	return rss, nil
+	return feed, nil
 }
 
-func (p *PttParser) addArticleToFeed(feed *feeds.Feed, article Article) error {
-	resp, err := p.pttGet(article.Url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+func (p *PttParser) addArticleToFeed(feed *feeds.Feed, article Article) (err error) {
+	ctx, span := tracing.Start(p.context(), "ptt.article", attribute.String("url", article.Url))
+	defer func() { tracing.End(span, err) }()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := p.fetchArticleDoc(ctx, article.Url)
 	if err != nil {
 		return err
 	}
+	_, parseSpan := tracing.Start(ctx, "ptt.parse_article")
+	defer parseSpan.End()
 
 	// Parse author
 	author := doc.Find("div.article-metaline span.article-meta-value").First().Text()
@@ -110,10 +161,21 @@ func (p *PttParser) addArticleToFeed(feed *feeds.Feed, article Article) error {
 		return err
 	}
 
-	fmt.Printf("標題: %s, 文章時間: %v, 網址: %s\n", article.Title, createdTime, article.Url)
+	// 列表只篩過標題與帳號，推文數要到文章頁才知道；與 trending 相同的計算方式
+	if p.Filter != nil {
+		pushes, _ := countPushes(parseComments(doc))
+		if !p.Filter.Match(filterEntry{Title: article.Title, Author: author, Pushes: pushes}) {
+			return nil
+		}
+	}
+
+	p.log().Debug("文章", "title", article.Title, "time", createdTime, "url", article.Url)
 
-	// Keep original html as the description
-	originalHtml, err := doc.Find("div#main-content").Html()
+	// Keep original html as the description; the header lines are already
+	// the item's author, title and date
+	content := doc.Find("div#main-content")
+	content.Find(articleMetaLines).Remove()
+	originalHtml, err := content.Html()
 	if err != nil {
 		return err
 	}
@@ -128,7 +190,7 @@ func (p *PttParser) addArticleToFeed(feed *feeds.Feed, article Article) error {
 	feed.Add(&feeds.Item{
 		Title:       article.Title,
 		Link:        &feeds.Link{Href: bepttURL},
-		Description: originalHtml,
+		Description: itemDescription(originalHtml, article.Url),
 		Author:      &feeds.Author{Name: author},
 		Created:     createdTime,
 	})
@@ -136,9 +198,13 @@ func (p *PttParser) addArticleToFeed(feed *feeds.Feed, article Article) error {
 	return nil
 }
 
-func (p *PttParser) fetchSearchResultPage(board string, keyword string, page int) ([]Article, error) {
+func (p *PttParser) fetchSearchResultPage(board string, keyword string, page int) (articles []Article, err error) {
+	ctx, span := tracing.Start(p.context(), "ptt.search_page",
+		attribute.String("board", board), attribute.String("keyword", keyword), attribute.Int("page", page))
+	defer func() { tracing.End(span, err) }()
+
 	searchUrl := pttSearchURL(board, keyword, page)
-	resp, err := p.pttGet(searchUrl)
+	resp, err := p.pttGet(ctx, searchUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -149,16 +215,21 @@ func (p *PttParser) fetchSearchResultPage(board string, keyword string, page int
 		return nil, err
 	}
 
-	var articles []Article
-	doc.Find("div.r-ent div.title a").Each(func(index int, element *goquery.Selection) {
+	doc.Find("div.r-ent").Each(func(index int, entry *goquery.Selection) {
+		element := entry.Find("div.title a")
 		title := strings.TrimSpace(element.Text())
 		link, _ := element.Attr("href")
 		if title == "" || link == "" {
 			return
 		}
+		// 不符合篩選條件的文章不必抓內文
+		author := strings.TrimSpace(entry.Find("div.meta div.author").Text())
+		if !p.Filter.Match(filterEntry{Title: title, Author: author, Pushes: -1}) {
+			return
+		}
 		article := Article{
 			Title: title,
-			Url:   "https://www.ptt.cc" + link,
+			Url:   pttOrigin + link,
 		}
 		articles = append(articles, article)
 	})
@@ -167,7 +238,7 @@ func (p *PttParser) fetchSearchResultPage(board string, keyword string, page int
 }
 
 func pttSearchURL(board string, keyword string, page int) string {
-	return fmt.Sprintf("https://www.ptt.cc/bbs/%s/search?page=%d&q=%s", board, page, url.QueryEscape(keyword))
+	return fmt.Sprintf("%s/bbs/%s/search?page=%d&q=%s", pttOrigin, board, page, url.QueryEscape(keyword))
 }
 
 func parsePositiveInt(value string, fallback int, min int, max int) int {
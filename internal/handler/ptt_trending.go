@@ -2,39 +2,27 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/Harrison-Dev/go_feed_tool/internal/media"
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/tracing"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// PredictService URL (configured via environment variable)
-var PredictServiceURL = getEnvOrDefault("PREDICT_SERVICE_URL", "http://localhost:5000")
-var predictionTimeWindow = getEnvInt("PREDICTION_TIME_WINDOW", 10) // minutes; should match model
-
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if v, err := strconv.Atoi(value); err == nil {
-			return v
-		}
-	}
-	return defaultValue
-}
-
 // PredictRequest matches the FastAPI service request schema
 type PredictRequest struct {
 	Board          string `json:"board"`
@@ -47,7 +35,9 @@ type PredictRequest struct {
 	DayOfWeek      int    `json:"day_of_week"`
 	TitleLength    int    `json:"title_length"`
 	HasImage       bool   `json:"has_image"`
+	ImageCount     int    `json:"image_count"`
 	TagType        string `json:"tag_type"`
+	Model          string `json:"model,omitempty"` // 看板專用模型檔名
 }
 
 // PredictResponse from the FastAPI service
@@ -62,8 +52,13 @@ type TrendingArticle struct {
 	PostTime    time.Time
 	Comments    []Comment
 	Probability float64
-	PushCount   int  // 推文數
-	IsViral     bool // 是否已爆文 (push >= 100)
+	PushCount   int       // 推文數
+	BooCount    int       // 噓文數
+	ListScore   int       // 看板列表的推文數標記 (nrec)，見 parseNrec
+	IsViral     bool      // 是否已爆文 (score >= trending.viral_pushes)
+	IsDisputed  bool      // 是否為爭議文 (噓文數 >= trending.controversial_boos)
+	ListDate    time.Time // 看板列表上的發文日期 (只有月/日)
+	ListTime    time.Time // 由文章代碼推得的發文時間，無法解析時為 zero
 }
 
 // Comment represents a PTT comment
@@ -74,92 +69,165 @@ type Comment struct {
 	Time    string
 }
 
-// GetPttTrending handles GET /ptt/trending?board=C_Chat&threshold=0.6&mode=all
-// mode: "viral" (已爆文), "potential" (潛在爆文), "all" (兩者都要, 預設)
-func GetPttTrending(w http.ResponseWriter, r *http.Request) {
-	parser := NewPttParser(http.DefaultClient)
+// TrendingOptions controls FetchTrending. Zero ViralPushes and MaxAge fall
+// back to the board profile from config.
+type TrendingOptions struct {
+	Board       string
+	Threshold   float64
+	Limit       int
+	Mode        string        // "viral", "potential", "all" or "controversial"
+	Score       string        // "push" (推文數, 預設) or "net" (推 - 噓)
+	ViralPushes int           // 分數達此值視為已爆文
+	MaxAge      time.Duration // 潛在爆文最多看多久內的文章
+	Since       time.Duration // 只看此時間內的文章，往回翻頁直到超出範圍
+	MaxPages    int           // 翻頁上限，不可超過 trending.max_pages
+}
 
-	board := r.URL.Query().Get("board")
-	if board == "" {
-		board = "C_Chat"
+// TrendingOptionsFromQuery reads /ptt/trending query parameters, filling in
+// the board profile defaults for anything missing or malformed. An unknown
+// mode or score is a ParamError.
+func TrendingOptionsFromQuery(q url.Values) (TrendingOptions, error) {
+	opts := TrendingOptions{
+		Board: q.Get("board"),
+		Limit: current.Trending.DefaultLimit,
 	}
-
-	thresholdStr := r.URL.Query().Get("threshold")
-	threshold := 0.5 // default
-	if thresholdStr != "" {
-		if t, err := strconv.ParseFloat(thresholdStr, 64); err == nil {
-			threshold = t
-		}
+	if opts.Board == "" {
+		opts.Board = "C_Chat"
 	}
-
-	limitStr := r.URL.Query().Get("limit")
-	limit := 20 // default
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
-		}
+	var err error
+	if opts.Mode, err = enumParam(q, "mode", "all", "viral", "potential", "all", "controversial"); err != nil {
+		return TrendingOptions{}, err
+	}
+	if opts.Score, err = enumParam(q, "score", "push", "push", "net"); err != nil {
+		return TrendingOptions{}, err
 	}
 
-	mode := r.URL.Query().Get("mode")
-	if mode == "" {
-		mode = "all"
+	profile := current.Profile(opts.Board)
+	opts.Threshold = profile.DefaultThreshold
+	if t, err := strconv.ParseFloat(q.Get("threshold"), 64); err == nil {
+		opts.Threshold = t
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		opts.Limit = l
+	}
+	if v, err := strconv.Atoi(q.Get("viral_pushes")); err == nil && v > 0 {
+		opts.ViralPushes = v
 	}
+	if d, err := time.ParseDuration(q.Get("max_age")); err == nil && d > 0 {
+		opts.MaxAge = d
+	}
+	if d, err := time.ParseDuration(q.Get("since")); err == nil && d > 0 {
+		opts.Since = d
+	}
+	if v, err := strconv.Atoi(q.Get("max_pages")); err == nil && v > 0 {
+		opts.MaxPages = v
+	}
+	return opts, nil
+}
 
-	rss, err := parser.FetchTrendingArticles(board, threshold, limit, mode)
+// GetPttTrending handles GET /ptt/trending?board=C_Chat&threshold=0.6&mode=all
+// mode: "viral" (已爆文), "potential" (潛在爆文), "all" (兩者都要, 預設),
+// "controversial" (爭議文)
+func GetPttTrending(w http.ResponseWriter, r *http.Request) {
+	serveFeed(w, r, buildPttTrending)
+}
+
+func buildPttTrending(r *http.Request, filter *Filter) (*feeds.Feed, error) {
+	opts, err := TrendingOptionsFromQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-
-	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
-	w.Write([]byte(rss))
+	p := NewPttParserForRequest(r)
+	p.Filter = filter
+	return p.BuildTrendingFeed(opts)
 }
 
 // FetchTrendingArticles fetches recent articles and predicts viral potential
 // mode: "viral" (已爆文), "potential" (潛在爆文), "all" (兩者都要)
 func (p *PttParser) FetchTrendingArticles(board string, threshold float64, limit int, mode string) (string, error) {
+	return p.FetchTrending(TrendingOptions{Board: board, Threshold: threshold, Limit: limit, Mode: mode})
+}
+
+// FetchTrending renders the trending feed described by opts as RSS.
+func (p *PttParser) FetchTrending(opts TrendingOptions) (string, error) {
+	feed, err := p.BuildTrendingFeed(opts)
+	if err != nil {
+		return "", err
+	}
+	return feed.ToRss()
+}
+
+// BuildTrendingFeed fetches recent articles and predicts viral potential
+// using the board profile, overridden by any non-zero fields in opts.
+func (p *PttParser) BuildTrendingFeed(opts TrendingOptions) (*feeds.Feed, error) {
+	board, threshold, limit, mode := opts.Board, opts.Threshold, opts.Limit, opts.Mode
 	if board == "" {
-		return "", fmt.Errorf("error: board name cannot be empty")
+		return nil, fmt.Errorf("error: board name cannot be empty")
+	}
+
+	profile := current.Profile(board)
+	if opts.ViralPushes > 0 {
+		profile.ViralPushes = opts.ViralPushes
+	}
+	if opts.MaxAge > 0 {
+		profile.PotentialMaxAge = opts.MaxAge
 	}
 
-	// Fetch recent articles (last 3 pages to get ~60 articles)
-	articles, err := p.fetchRecentArticles(board, 3)
+	// Fetch recent articles, skipping the detail page of anything the list
+	// already rules out
+	now := Now()
+	needDetails := func(article *TrendingArticle) bool {
+		return p.Filter.Match(filterEntry{Title: article.Title, Author: article.Author, Pushes: -1}) &&
+			needsDetails(article, opts, profile, now)
+	}
+	articles, err := p.fetchRecentArticles(board, trendingWalk(opts, current.Trending, profile, now), needDetails)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch articles: %w", err)
+		return nil, fmt.Errorf("failed to fetch articles: %w", err)
 	}
 
 	var viralArticles []TrendingArticle
 	var potentialArticles []TrendingArticle
 
-	cutoffTime := time.Now().Add(-time.Duration(predictionTimeWindow) * time.Minute)
-	maxPotentialAge := time.Now().Add(-2 * time.Hour) // 潛在爆文最多看 2 小時內
+	cutoffTime := now.Add(-time.Duration(predictionTimeWindow) * time.Minute)
+	maxPotentialAge := now.Add(-profile.PotentialMaxAge) // 潛在爆文最多看 2 小時內 (預設)
 
 	for _, article := range articles {
-		// 計算推文數
-		pushCount := 0
-		for _, c := range article.Comments {
-			if c.Type == "推" {
-				pushCount++
+		// 計算推文數與噓文數
+		article.PushCount, article.BooCount = countPushes(article.Comments)
+
+		// 在 limit 之前篩選，被排除的文章不佔名額
+		if !p.Filter.Match(filterEntry{Title: article.Title, Author: article.Author, Pushes: max(article.PushCount, article.ListScore)}) {
+			continue
+		}
+
+		// 爭議文: 噓文數 >= controversial_boos (列表 X1~XX 標記可作為下限)
+		if mode == "controversial" {
+			if boos := max(article.BooCount, -article.ListScore); boos >= profile.ControversialBoos {
+				article.BooCount = boos
+				article.IsDisputed = true
+				viralArticles = append(viralArticles, article)
 			}
+			continue
 		}
-		article.PushCount = pushCount
 
-		// 已爆文: 推文數 >= 100
-		if pushCount >= 100 {
+		// 已爆文: 分數 >= viral_pushes (預設 100)
+		if viralScore(article, opts.Score) >= profile.ViralPushes {
 			article.IsViral = true
 			article.Probability = 1.0
+			// 列表淨分是推文數的下限，標題不應顯示比它少的推文數
+			article.PushCount = max(article.PushCount, article.ListScore)
 			if mode == "viral" || mode == "all" {
 				viralArticles = append(viralArticles, article)
 			}
 			continue
 		}
 
-		// 潛在爆文: 發文 15 分鐘以上、2 小時內，且預測機率高
+		// 潛在爆文: 發文超過預測時窗、未超過 potential_max_age，且預測機率高
 		if mode == "potential" || mode == "all" {
 			if article.PostTime.Before(cutoffTime) && article.PostTime.After(maxPotentialAge) {
-				prob, err := p.predictViral(board, &article)
+				prob, err := p.predictViral(board, profile.Model, &article)
 				if err != nil {
-					fmt.Printf("Prediction error for %s: %v\n", article.Title, err)
+					p.log().Warn("預測失敗", "title", article.Title, "url", article.Url, "err", err)
 					continue
 				}
 
@@ -180,101 +248,254 @@ func (p *PttParser) FetchTrendingArticles(board string, threshold float64, limit
 	sortByPostTime(result)
 
 	// 限制數量
-	if len(result) > limit {
+	if limit > 0 && len(result) > limit {
 		result = result[:limit]
 	}
 
-	// Generate RSS feed
 	return p.generateTrendingFeed(board, threshold, result, mode)
 }
 
 // pttGet makes a GET request with over18 cookie
-func (p *PttParser) pttGet(url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (p *PttParser) pttGet(ctx context.Context, url string) (*http.Response, error) {
+	if p.BaseURL != "" && p.BaseURL != pttOrigin {
+		url = p.BaseURL + strings.TrimPrefix(url, pttOrigin)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Cookie", "over18=1")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	req.Header.Set("User-Agent", current.Upstream.UserAgent)
 	return p.HttpClient.Do(req)
 }
 
-// fetchRecentArticles fetches recent articles from a board
-func (p *PttParser) fetchRecentArticles(board string, pages int) ([]TrendingArticle, error) {
+// boardWalk bounds how far fetchRecentArticles walks back through 上頁 links.
+type boardWalk struct {
+	Pages int       // 最多抓幾頁
+	Since time.Time // 遇到早於此時間的文章就停止並略過它們；zero 表示固定抓 Pages 頁
+}
+
+// fetchRecentArticles fetches recent articles from a board. needDetails
+// decides from list-page metadata whether an article page is worth fetching;
+// nil fetches every article. Pinned posts (置底文) are skipped.
+func (p *PttParser) fetchRecentArticles(board string, walk boardWalk, needDetails func(*TrendingArticle) bool) ([]TrendingArticle, error) {
 	var articles []TrendingArticle
-	var prevLink string
-
-	for page := 1; page <= pages; page++ {
-		var pageURL string
-		if page == 1 {
-			pageURL = fmt.Sprintf("https://www.ptt.cc/bbs/%s/index.html", board)
-		} else if prevLink != "" {
-			pageURL = "https://www.ptt.cc" + prevLink
-		} else {
-			break
-		}
+	pageURL := fmt.Sprintf("%s/bbs/%s/index.html", pttOrigin, board)
 
-		resp, err := p.pttGet(pageURL)
+	for page := 1; page <= walk.Pages && pageURL != ""; page++ {
+		doc, err := p.fetchIndexPage(pageURL)
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
 
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
-		if err != nil {
-			return nil, err
+		// Find the previous page link for next iteration
+		pageURL = ""
+		if prevLink, ok := doc.Find("a.btn.wide:contains('上頁')").Attr("href"); ok {
+			pageURL = pttOrigin + prevLink
 		}
 
-		// Find the previous page link for next iteration
-		prevLink, _ = doc.Find("a.btn.wide:contains('上頁')").Attr("href")
-
-		// Parse articles from page
-		doc.Find("div.r-ent").Each(func(i int, s *goquery.Selection) {
-			titleElem := s.Find("div.title a")
-			title := titleElem.Text()
-			link, exists := titleElem.Attr("href")
-			if !exists || title == "" {
-				return
-			}
+		_, parseSpan := tracing.Start(p.context(), "ptt.parse_index", attribute.String("board", board))
+		listed := parseIndexPage(doc)
+		parseSpan.SetAttributes(attribute.Int("articles", len(listed)))
+		parseSpan.End()
 
-			// Get push count (nrec)
-			nrec := s.Find("div.nrec span").Text()
+		reachedHorizon := false
+		for _, article := range listed {
+			if !walk.Since.IsZero() && postedBefore(&article, walk.Since) {
+				reachedHorizon = true
+				continue
+			}
 
-			article := TrendingArticle{
-				Article: Article{
-					Title: title,
-					Url:   "https://www.ptt.cc" + link,
-				},
+			if needDetails != nil && !needDetails(&article) {
+				continue
 			}
 
 			// Fetch article details (post time, comments)
 			if err := p.fetchArticleDetails(&article); err != nil {
-				fmt.Printf("Error fetching details for %s: %v\n", title, err)
-				return
+				p.log().Warn("抓取文章內容失敗", "title", article.Title, "url", article.Url, "err", err)
+				metrics.ParseFailures.WithLabelValues("ptt_trending").Inc()
+				continue
 			}
 
 			// Only include articles with some activity
-			if nrec != "" || len(article.Comments) > 0 {
+			if article.ListScore != 0 || len(article.Comments) > 0 {
 				articles = append(articles, article)
 			}
-		})
+		}
+		if reachedHorizon {
+			break
+		}
 	}
 
 	return articles, nil
 }
 
-// fetchArticleDetails fetches post time and comments for an article
-func (p *PttParser) fetchArticleDetails(article *TrendingArticle) error {
-	resp, err := p.pttGet(article.Url)
+func (p *PttParser) fetchIndexPage(pageURL string) (doc *goquery.Document, err error) {
+	ctx, span := tracing.Start(p.context(), "ptt.index_page", attribute.String("url", pageURL))
+	defer func() { tracing.End(span, err) }()
+
+	resp, err := p.pttGet(ctx, pageURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ptt returned %d for %s", resp.StatusCode, pageURL)
+	}
+	return goquery.NewDocumentFromReader(resp.Body)
+}
+
+// parseIndexPage reads the article list of a board index page. Entries after
+// div.r-list-sep are pinned posts at the bottom of the newest page and are
+// left out, since they are old and would end the walk immediately.
+func parseIndexPage(doc *goquery.Document) []TrendingArticle {
+	var articles []TrendingArticle
+	pinned := false
+	now := Now()
+
+	doc.Find("div.r-ent, div.r-list-sep").Each(func(i int, s *goquery.Selection) {
+		if s.HasClass("r-list-sep") {
+			pinned = true
+		}
+		if pinned {
+			return
+		}
+
+		titleElem := s.Find("div.title a")
+		title := titleElem.Text()
+		link, exists := titleElem.Attr("href")
+		// 已刪除的文章沒有連結
+		if !exists || title == "" {
+			return
+		}
+
+		// Get push count (nrec)
+		nrec := s.Find("div.nrec span").Text()
+
+		article := TrendingArticle{
+			Article: Article{
+				Title: title,
+				Url:   pttOrigin + link,
+			},
+			Author:    strings.TrimSpace(s.Find("div.meta div.author").Text()),
+			ListScore: parseNrec(nrec),
+			ListDate:  parseListDate(s.Find("div.meta div.date").Text(), now),
+			ListTime:  articleIDTime(link),
+		}
+		articles = append(articles, article)
+	})
+
+	return articles
+}
+
+// articleIDTime extracts the posting time encoded in PTT article file names,
+// e.g. /bbs/C_Chat/M.1706000000.A.1B2.html was posted at Unix 1706000000.
+func articleIDTime(link string) time.Time {
+	name := path.Base(link)
+	if !strings.HasPrefix(name, "M.") {
+		return time.Time{}
+	}
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) < 3 {
+		return time.Time{}
+	}
+	sec, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	taipeiLoc, _ := time.LoadLocation("Asia/Taipei")
+	return time.Unix(sec, 0).In(taipeiLoc)
+}
+
+// trendingWalk decides how many index pages FetchTrending reads. Without a
+// time horizon it reads a fixed number of pages (default 3, ~60 articles),
+// which covers minutes on Gossiping but weeks on small boards; with one it
+// walks back until the horizon within the max_pages budget. Potential mode
+// always has a horizon: nothing older than potential_max_age can qualify.
+func trendingWalk(opts TrendingOptions, trending config.TrendingConfig, profile config.BoardProfile, now time.Time) boardWalk {
+	since := opts.Since
+	if since == 0 && opts.Mode == "potential" {
+		since = profile.PotentialMaxAge
+	}
+	if since == 0 {
+		return boardWalk{Pages: trending.Pages}
+	}
+
+	pages := trending.MaxPages
+	if opts.MaxPages > 0 && opts.MaxPages < pages {
+		pages = opts.MaxPages
+	}
+	return boardWalk{Pages: pages, Since: now.Add(-since)}
+}
+
+// needsDetails reports whether the list-page metadata of article leaves any
+// chance of it showing up in the feed, so its article page must be fetched.
+func needsDetails(article *TrendingArticle, opts TrendingOptions, profile config.BoardProfile, now time.Time) bool {
+	// 公告與已刪除文章 (作者顯示為 -) 不會出現在熱門列表
+	if extractTagType(article.Title) == "公告" || article.Author == "-" {
+		return false
+	}
+
+	switch opts.Mode {
+	case "potential":
+		// 列表已顯示達到爆文門檻的文章不會是潛在爆文
+		if article.ListScore >= profile.ViralPushes {
+			return false
+		}
+		// 超出潛在爆文時間範圍
+		if postedBefore(article, now.Add(-profile.PotentialMaxAge)) {
+			return false
+		}
+	case "viral":
+		// 淨分模式下，列表數字就是淨分；未達門檻的文章不可能是已爆文
+		if opts.Score == "net" && article.ListScore < 100 && article.ListScore < profile.ViralPushes {
+			return false
+		}
+	}
+	return true
+}
+
+// postedBefore reports whether list metadata proves article was posted
+// before t. The article ID time is exact; the list date only has a day, so
+// the whole day must be before t.
+func postedBefore(article *TrendingArticle, t time.Time) bool {
+	if !article.ListTime.IsZero() {
+		return article.ListTime.Before(t)
+	}
+	if !article.ListDate.IsZero() {
+		return article.ListDate.AddDate(0, 0, 1).Before(t)
+	}
+	return false
+}
+
+// parseListDate parses the " 1/22" date shown on board list pages. The list
+// omits the year, so dates later than tomorrow are taken as last year.
+func parseListDate(text string, now time.Time) time.Time {
+	var month, day int
+	if _, err := fmt.Sscanf(strings.TrimSpace(text), "%d/%d", &month, &day); err != nil {
+		return time.Time{}
+	}
+	taipeiLoc, _ := time.LoadLocation("Asia/Taipei")
+	now = now.In(taipeiLoc)
+	date := time.Date(now.Year(), time.Month(month), day, 0, 0, 0, 0, taipeiLoc)
+	if date.After(now.AddDate(0, 0, 1)) {
+		date = date.AddDate(-1, 0, 0)
+	}
+	return date
+}
+
+// fetchArticleDetails fetches post time and comments for an article
+func (p *PttParser) fetchArticleDetails(article *TrendingArticle) (err error) {
+	ctx, span := tracing.Start(p.context(), "ptt.article", attribute.String("url", article.Url))
+	defer func() { tracing.End(span, err) }()
+
+	doc, err := p.fetchArticleDoc(ctx, article.Url)
 	if err != nil {
 		return err
 	}
+	_, parseSpan := tracing.Start(ctx, "ptt.parse_article")
+	defer parseSpan.End()
 
 	// Parse author (first meta value)
 	article.Author = doc.Find("div.article-metaline span.article-meta-value").First().Text()
@@ -286,10 +507,23 @@ func (p *PttParser) fetchArticleDetails(article *TrendingArticle) error {
 	if postTime, err := time.ParseInLocation(layout, timeText, taipeiLoc); err == nil {
 		article.PostTime = postTime
 	} else {
-		article.PostTime = time.Now().Add(-1 * time.Hour) // default
+		article.PostTime = Now().Add(-1 * time.Hour) // default
 	}
 
-	// Parse comments
+	article.Comments = parseComments(doc)
+
+	// Parse content for image detection
+	main := doc.Find("div#main-content")
+	main.Find(articleMetaLines).Remove()
+	content, _ := main.Html()
+	article.Summary = content
+
+	return nil
+}
+
+// parseComments returns the 推/噓/→ comments of an article page.
+func parseComments(doc *goquery.Document) []Comment {
+	var comments []Comment
 	doc.Find("div.push").Each(func(i int, s *goquery.Selection) {
 		pushTag := s.Find("span.push-tag").Text()
 		pushUser := s.Find("span.push-userid").Text()
@@ -305,23 +539,19 @@ func (p *PttParser) fetchArticleDetails(article *TrendingArticle) error {
 			pushType = "噓"
 		}
 
-		article.Comments = append(article.Comments, Comment{
+		comments = append(comments, Comment{
 			Type:    pushType,
 			User:    strings.TrimSpace(pushUser),
 			Content: strings.TrimPrefix(pushContent, ": "),
 			Time:    strings.TrimSpace(pushTime),
 		})
 	})
-
-	// Parse content for image detection
-	content, _ := doc.Find("div#main-content").Html()
-	article.Summary = content
-
-	return nil
+	return comments
 }
 
-// predictViral calls the prediction service
-func (p *PttParser) predictViral(board string, article *TrendingArticle) (float64, error) {
+// predictViral calls the prediction service; model selects a board-specific
+// model file and may be empty for the service default.
+func (p *PttParser) predictViral(board string, model string, article *TrendingArticle) (float64, error) {
 	// Calculate 15-minute features
 	cutoff := article.PostTime.Add(time.Duration(predictionTimeWindow) * time.Minute)
 	var commentsWindow, pushWindow, booWindow int
@@ -341,8 +571,8 @@ func (p *PttParser) predictViral(board string, article *TrendingArticle) (float6
 	// Extract tag type from title
 	tagType := extractTagType(article.Title)
 
-	// Check for image
-	hasImage := strings.Contains(article.Summary, "imgur.com")
+	// imgur、pbs.twimg 圖片數量；舊模型只使用 has_image
+	imageCount := len(media.Images(article.Summary))
 
 	req := PredictRequest{
 		Board:          board,
@@ -354,11 +584,59 @@ func (p *PttParser) predictViral(board string, article *TrendingArticle) (float6
 		HourOfDay:      article.PostTime.Hour(),
 		DayOfWeek:      int(article.PostTime.Weekday()),
 		TitleLength:    len(article.Title),
-		HasImage:       hasImage,
+		HasImage:       imageCount > 0,
+		ImageCount:     imageCount,
 		TagType:        tagType,
+		Model:          model,
 	}
 
-	return callPredictService(req)
+	return callPredictService(p.context(), req)
+}
+
+// countPushes counts 推 and 噓 comments
+func countPushes(comments []Comment) (pushes int, boos int) {
+	for _, c := range comments {
+		switch c.Type {
+		case "推":
+			pushes++
+		case "噓":
+			boos++
+		}
+	}
+	return pushes, boos
+}
+
+// viralScore returns the score compared against viral_pushes. The list-page
+// nrec is a lower bound for both scores, so a "爆" article counts as viral
+// even when its comments could not all be parsed.
+func viralScore(article TrendingArticle, score string) int {
+	value := article.PushCount
+	if score == "net" {
+		value = article.PushCount - article.BooCount
+	}
+	return max(value, article.ListScore)
+}
+
+// parseNrec converts the list-page 推文數 marker into a net score:
+// "" is 0, numbers are exact, "爆" means 100 or more, "X1".."X9" mean
+// -10..-90 or worse and "XX" means -100 or worse.
+func parseNrec(nrec string) int {
+	nrec = strings.TrimSpace(nrec)
+	switch {
+	case nrec == "":
+		return 0
+	case nrec == "爆":
+		return 100
+	case nrec == "XX":
+		return -100
+	case strings.HasPrefix(nrec, "X"):
+		if n, err := strconv.Atoi(nrec[1:]); err == nil {
+			return -10 * n
+		}
+		return 0
+	}
+	n, _ := strconv.Atoi(nrec)
+	return n
 }
 
 // parseCommentTime parses PTT comment time format (MM/DD HH:MM)
@@ -406,15 +684,35 @@ func extractTagType(title string) string {
 	return ""
 }
 
-// callPredictService makes HTTP request to prediction service
-func callPredictService(req PredictRequest) (float64, error) {
+// callPredictService makes HTTP request to prediction service; the trace
+// context of ctx is forwarded in the traceparent header
+func callPredictService(ctx context.Context, req PredictRequest) (prob float64, err error) {
+	ctx, span := tracing.Start(ctx, "predict",
+		attribute.String("board", req.Board), attribute.String("model", req.Model))
+	defer func() {
+		span.SetAttributes(attribute.Float64("probability", prob))
+		tracing.End(span, err)
+	}()
+
+	start := time.Now()
+	prob, err = postPrediction(ctx, req)
+	metrics.ObservePrediction(time.Since(start), err)
+	return prob, err
+}
+
+func postPrediction(ctx context.Context, req PredictRequest) (float64, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return 0, err
 	}
 
 	url := PredictServiceURL + "/predict"
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := newPredictClient().Do(httpReq)
 	if err != nil {
 		return 0, fmt.Errorf("predict service error: %w", err)
 	}
@@ -433,31 +731,29 @@ func callPredictService(req PredictRequest) (float64, error) {
 	return predictResp.Probability, nil
 }
 
-// sortByPostTime sorts articles by post time descending (newest first)
+// sortByPostTime sorts articles by post time descending (newest first),
+// keeping list order for articles posted at the same time
 func sortByPostTime(articles []TrendingArticle) {
-	for i := 0; i < len(articles)-1; i++ {
-		for j := i + 1; j < len(articles); j++ {
-			if articles[j].PostTime.After(articles[i].PostTime) {
-				articles[i], articles[j] = articles[j], articles[i]
-			}
-		}
-	}
+	sort.SliceStable(articles, func(i, j int) bool {
+		return articles[i].PostTime.After(articles[j].PostTime)
+	})
 }
 
-// generateTrendingFeed creates RSS feed from trending articles
-func (p *PttParser) generateTrendingFeed(board string, threshold float64, articles []TrendingArticle, mode string) (string, error) {
+// generateTrendingFeed creates a feed from trending articles
+func (p *PttParser) generateTrendingFeed(board string, threshold float64, articles []TrendingArticle, mode string) (*feeds.Feed, error) {
 	modeDesc := map[string]string{
-		"viral":     "已爆文",
-		"potential": "潛在爆文",
-		"all":       "已爆文+潛在爆文",
+		"viral":         "已爆文",
+		"potential":     "潛在爆文",
+		"all":           "已爆文+潛在爆文",
+		"controversial": "爭議文",
 	}
 
 	feed := &feeds.Feed{
 		Title:       fmt.Sprintf("PTT %s %s", board, modeDesc[mode]),
-		Link:        &feeds.Link{Href: fmt.Sprintf("https://www.ptt.cc/bbs/%s/index.html", board)},
+		Link:        &feeds.Link{Href: fmt.Sprintf("%s/bbs/%s/index.html", pttOrigin, board)},
 		Description: fmt.Sprintf("PTT %s 熱門文章 (預測門檻: %.0f%%)", board, threshold*100),
 		Author:      &feeds.Author{Name: "PTT Viral Predictor"},
-		Created:     time.Now(),
+		Created:     Now(),
 	}
 
 	for _, article := range articles {
@@ -466,7 +762,9 @@ func (p *PttParser) generateTrendingFeed(board string, threshold float64, articl
 
 		// 標題格式: 已爆文顯示推文數，潛在爆文顯示預測機率
 		var title string
-		if article.IsViral {
+		if article.IsDisputed {
+			title = fmt.Sprintf("[💢%d噓] %s", article.BooCount, article.Title)
+		} else if article.IsViral {
 			title = fmt.Sprintf("[🔥%d推] %s", article.PushCount, article.Title)
 		} else {
 			title = fmt.Sprintf("[📈%.0f%%] %s", article.Probability*100, article.Title)
@@ -480,11 +778,11 @@ func (p *PttParser) generateTrendingFeed(board string, threshold float64, articl
 		feed.Add(&feeds.Item{
 			Title:       title,
 			Link:        &feeds.Link{Href: bepttURL},
-			Description: description,
+			Description: itemDescription(description, article.Url),
 			Author:      &feeds.Author{Name: article.Author},
 			Created:     article.PostTime,
 		})
 	}
 
-	return feed.ToRss()
+	return feed, nil
 }
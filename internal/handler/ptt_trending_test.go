@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/PuerkitoBio/goquery"
 )
 
 func TestExtractTagType(t *testing.T) {
@@ -150,7 +156,7 @@ func TestCallPredictService(t *testing.T) {
 				TagType:        "閒聊",
 			}
 
-			prob, err := callPredictService(req)
+			prob, err := callPredictService(context.Background(), req)
 			if err != nil {
 				t.Errorf("callPredictService() error = %v", err)
 				return
@@ -163,6 +169,190 @@ func TestCallPredictService(t *testing.T) {
 	}
 }
 
+func TestParseNrec(t *testing.T) {
+	tests := []struct {
+		nrec     string
+		expected int
+	}{
+		{"", 0},
+		{"42", 42},
+		{"爆", 100},
+		{"X1", -10},
+		{"X5", -50},
+		{"XX", -100},
+		{" 7 ", 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nrec, func(t *testing.T) {
+			if result := parseNrec(tt.nrec); result != tt.expected {
+				t.Errorf("parseNrec(%q) = %d, want %d", tt.nrec, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestViralScore(t *testing.T) {
+	article := TrendingArticle{PushCount: 120, BooCount: 40, ListScore: 80}
+
+	if score := viralScore(article, "push"); score != 120 {
+		t.Errorf("push score = %d, want 120", score)
+	}
+	// net 80 from comments, list says 80
+	if score := viralScore(article, "net"); score != 80 {
+		t.Errorf("net score = %d, want 80", score)
+	}
+
+	// 爆 on the list page wins over partially parsed comments
+	article = TrendingArticle{PushCount: 30, ListScore: parseNrec("爆")}
+	if score := viralScore(article, "push"); score != 100 {
+		t.Errorf("score with 爆 marker = %d, want 100", score)
+	}
+}
+
+func TestParseListDate(t *testing.T) {
+	taipeiLoc, _ := time.LoadLocation("Asia/Taipei")
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, taipeiLoc)
+
+	date := parseListDate(" 1/02", now)
+	if !date.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, taipeiLoc)) {
+		t.Errorf("parseListDate(1/02) = %v", date)
+	}
+
+	// 12/31 seen on Jan 2 is last year
+	date = parseListDate("12/31", now)
+	if date.Year() != 2025 {
+		t.Errorf("parseListDate(12/31).Year() = %d, want 2025", date.Year())
+	}
+
+	if date := parseListDate("", now); !date.IsZero() {
+		t.Errorf("parseListDate(\"\") = %v, want zero", date)
+	}
+}
+
+func TestNeedsDetails(t *testing.T) {
+	taipeiLoc, _ := time.LoadLocation("Asia/Taipei")
+	now := time.Date(2026, 1, 22, 20, 0, 0, 0, taipeiLoc)
+	cfg := config.Default()
+	profile := cfg.Profile("C_Chat")
+	today := time.Date(2026, 1, 22, 0, 0, 0, 0, taipeiLoc)
+
+	tests := []struct {
+		name    string
+		article TrendingArticle
+		opts    TrendingOptions
+		want    bool
+	}{
+		{
+			name:    "announcement",
+			article: TrendingArticle{Article: Article{Title: "[公告] 板規"}, ListDate: today},
+			opts:    TrendingOptions{Mode: "all"},
+			want:    false,
+		},
+		{
+			name:    "deleted author",
+			article: TrendingArticle{Article: Article{Title: "[閒聊] test"}, Author: "-"},
+			opts:    TrendingOptions{Mode: "all"},
+			want:    false,
+		},
+		{
+			name:    "potential but older than window",
+			article: TrendingArticle{Article: Article{Title: "[閒聊] old"}, ListDate: today.AddDate(0, 0, -3)},
+			opts:    TrendingOptions{Mode: "potential"},
+			want:    false,
+		},
+		{
+			name:    "potential but already viral on list",
+			article: TrendingArticle{Article: Article{Title: "[閒聊] hot"}, ListScore: 100, ListDate: today},
+			opts:    TrendingOptions{Mode: "potential"},
+			want:    false,
+		},
+		{
+			name:    "viral by net score below threshold",
+			article: TrendingArticle{Article: Article{Title: "[閒聊] meh"}, ListScore: 35, ListDate: today},
+			opts:    TrendingOptions{Mode: "viral", Score: "net"},
+			want:    false,
+		},
+		{
+			name:    "viral by push count needs comments",
+			article: TrendingArticle{Article: Article{Title: "[閒聊] meh"}, ListScore: 35, ListDate: today},
+			opts:    TrendingOptions{Mode: "viral", Score: "push"},
+			want:    true,
+		},
+		{
+			name:    "recent article in all mode",
+			article: TrendingArticle{Article: Article{Title: "[閒聊] new"}, ListScore: 3, ListDate: today},
+			opts:    TrendingOptions{Mode: "all"},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsDetails(&tt.article, tt.opts, profile, now); got != tt.want {
+				t.Errorf("needsDetails() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIndexPageSkipsPinned(t *testing.T) {
+	page := `<div class="r-list-container">
+<div class="r-ent"><div class="nrec"><span>爆</span></div>
+  <div class="title"><a href="/bbs/C_Chat/M.1769083200.A.001.html">[閒聊] 一</a></div>
+  <div class="meta"><div class="author">alice</div><div class="date"> 1/22</div></div></div>
+<div class="r-ent"><div class="nrec"></div>
+  <div class="title">(本文已被刪除) [bob]</div>
+  <div class="meta"><div class="author">-</div><div class="date"> 1/22</div></div></div>
+<div class="r-ent"><div class="nrec"><span>X2</span></div>
+  <div class="title"><a href="/bbs/C_Chat/M.1769086800.A.002.html">[問卦] 二</a></div>
+  <div class="meta"><div class="author">carol</div><div class="date"> 1/22</div></div></div>
+<div class="r-list-sep"></div>
+<div class="r-ent"><div class="nrec"><span>12</span></div>
+  <div class="title"><a href="/bbs/C_Chat/M.1500000000.A.003.html">[公告] 置底</a></div>
+  <div class="meta"><div class="author">admin</div><div class="date"> 7/14</div></div></div>
+</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	articles := parseIndexPage(doc)
+	if len(articles) != 2 {
+		t.Fatalf("len(articles) = %d, want 2 (deleted and pinned skipped)", len(articles))
+	}
+	if articles[0].Author != "alice" || articles[0].ListScore != 100 {
+		t.Errorf("articles[0] = %+v", articles[0])
+	}
+	if articles[1].ListScore != -20 {
+		t.Errorf("articles[1].ListScore = %d, want -20", articles[1].ListScore)
+	}
+	if articles[1].ListTime.Unix() != 1769086800 {
+		t.Errorf("articles[1].ListTime = %v, want from article ID", articles[1].ListTime)
+	}
+}
+
+func TestTrendingWalk(t *testing.T) {
+	now := time.Now()
+	cfg := config.Default()
+	profile := cfg.Profile("C_Chat")
+
+	walk := trendingWalk(TrendingOptions{Mode: "all"}, cfg.Trending, profile, now)
+	if walk.Pages != 3 || !walk.Since.IsZero() {
+		t.Errorf("default walk = %+v, want 3 pages without horizon", walk)
+	}
+
+	walk = trendingWalk(TrendingOptions{Mode: "potential"}, cfg.Trending, profile, now)
+	if walk.Pages != 20 || !walk.Since.Equal(now.Add(-2*time.Hour)) {
+		t.Errorf("potential walk = %+v, want potential_max_age horizon", walk)
+	}
+
+	walk = trendingWalk(TrendingOptions{Mode: "viral", Since: 6 * time.Hour, MaxPages: 50}, cfg.Trending, profile, now)
+	if walk.Pages != 20 || !walk.Since.Equal(now.Add(-6*time.Hour)) {
+		t.Errorf("since walk = %+v, want 6h horizon capped at 20 pages", walk)
+	}
+}
+
 func TestSortByPostTime(t *testing.T) {
 	now := time.Now()
 	articles := []TrendingArticle{
@@ -187,6 +377,46 @@ func TestSortByPostTime(t *testing.T) {
 	}
 }
 
+func TestTrendingOptionsFromQuery(t *testing.T) {
+	cfg := config.Default()
+	cfg.Boards = map[string]config.BoardProfile{
+		"Steam": {DefaultThreshold: 0.3},
+	}
+	original := current
+	Configure(cfg)
+	defer Configure(original)
+
+	opts, err := TrendingOptionsFromQuery(url.Values{"board": {"Steam"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Threshold != 0.3 {
+		t.Errorf("threshold = %v, want Steam profile default 0.3", opts.Threshold)
+	}
+	if opts.Mode != "all" || opts.Limit != 20 {
+		t.Errorf("mode/limit = %q/%d, want all/20", opts.Mode, opts.Limit)
+	}
+
+	opts, _ = TrendingOptionsFromQuery(url.Values{
+		"board":        {"Steam"},
+		"threshold":    {"0.8"},
+		"viral_pushes": {"30"},
+		"max_age":      {"90m"},
+	})
+	if opts.Threshold != 0.8 || opts.ViralPushes != 30 || opts.MaxAge != 90*time.Minute {
+		t.Errorf("opts = %+v, want query overrides", opts)
+	}
+
+	opts, _ = TrendingOptionsFromQuery(url.Values{"viral_pushes": {"-5"}, "max_age": {"abc"}})
+	if opts.Board != "C_Chat" || opts.ViralPushes != 0 || opts.MaxAge != 0 {
+		t.Errorf("opts = %+v, want invalid overrides ignored", opts)
+	}
+
+	if _, err := TrendingOptionsFromQuery(url.Values{"mode": {"hot"}}); err == nil {
+		t.Error("mode=hot accepted, want ParamError")
+	}
+}
+
 func TestGetPttTrendingHandler(t *testing.T) {
 	// Create mock prediction service
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/logging"
+	"github.com/Harrison-Dev/go_feed_tool/internal/metrics"
+	"github.com/Harrison-Dev/go_feed_tool/internal/ratelimit"
+)
+
+// allowClient charges rt's cost to the client IP of r. Over the limit it
+// answers 429 with Retry-After and returns false; a request costing more
+// than rate_limit.burst could never pass and gets 400 instead.
+func allowClient(w http.ResponseWriter, r *http.Request, rt Route) bool {
+	if clients == nil {
+		return true
+	}
+	cost := routeCost(rt, r)
+	client := proxies.ClientIP(r)
+	ok, wait := clients.Take(client, time.Now(), cost)
+	if ok {
+		return true
+	}
+	if wait == ratelimit.Never {
+		// 重試也不會通過，回 400 而不是 Retry-After 數百年的 429
+		logging.FromRequest(r).Warn("請求成本超過上限", "client", client, "cost", cost, "burst", current.RateLimit.Burst)
+		writeJSONError(w, http.StatusBadRequest, "request_too_expensive")
+		return false
+	}
+
+	metrics.RateLimited.WithLabelValues(rt.Path).Inc()
+	logging.FromRequest(r).Warn("請求過於頻繁", "client", client, "cost", cost, "retry_after", wait)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeJSONError(w, http.StatusTooManyRequests, "rate_limited")
+	return false
+}
+
+// chargeUpstream charges the client of r for the upstream requests counted
+// by n beyond what allowClient charged up front, mostly article pages that
+// were not cached. The client may go into debt; its next requests wait.
+func chargeUpstream(r *http.Request, rt Route, n *atomic.Int64) {
+	if clients == nil {
+		return
+	}
+	if extra := float64(n.Load()) - routeCost(rt, r); extra > 0 {
+		clients.Charge(proxies.ClientIP(r), time.Now(), extra)
+	}
+}
+
+func routeCost(rt Route, r *http.Request) float64 {
+	if rt.Cost == nil {
+		return 1
+	}
+	return rt.Cost(r)
+}
+
+type upstreamCountKey struct{}
+
+// withUpstreamCount returns r with a counter of the upstream requests made
+// on its behalf.
+func withUpstreamCount(r *http.Request) (*http.Request, *atomic.Int64) {
+	n := new(atomic.Int64)
+	return r.WithContext(context.WithValue(r.Context(), upstreamCountKey{}, n)), n
+}
+
+// upstreamCounter counts the requests whose context carries a counter.
+type upstreamCounter struct {
+	next http.RoundTripper
+}
+
+func (c upstreamCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if n, ok := req.Context().Value(upstreamCountKey{}).(*atomic.Int64); ok {
+		n.Add(1)
+	}
+	return c.next.RoundTrip(req)
+}
+
+// searchCost is the number of search result pages /ptt/search fetches.
+// Article pages are charged afterwards by chargeUpstream.
+func searchCost(r *http.Request) float64 {
+	pages, err := strconv.Atoi(r.URL.Query().Get("pages"))
+	if err != nil {
+		return 1
+	}
+	return float64(clampInt(pages, 1, 5))
+}
+
+// trendingCost is the number of board index pages /ptt/trending walks.
+// Article pages are charged afterwards by chargeUpstream.
+func trendingCost(r *http.Request) float64 {
+	opts, err := TrendingOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		return 1
+	}
+	cfg := requestConfig(r)
+	return float64(trendingWalk(opts, cfg.Trending, cfg.Profile(opts.Board), Now()).Pages)
+}
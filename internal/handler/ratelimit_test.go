@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+)
+
+// 請求成本依上游請求數計算；參數錯誤的請求也計入，且不會打到上游
+func TestClientRateLimit(t *testing.T) {
+	original := current
+	defer Configure(original)
+	cfg := config.Default()
+	cfg.RateLimit.PerMinute = 60
+	cfg.RateLimit.Burst = 20
+	cfg.RateLimit.TrustedProxies = []string{"172.18.0.0/16"}
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	mounted := make(map[string]func(w http.ResponseWriter, r *http.Request))
+	for _, route := range Routes {
+		mounted[route.Path] = Mount(route)
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		remoteAddr     string
+		xff            string
+		expectedStatus int
+		retryAfter     string
+	}{
+		// 每次 5 個 token，20 個 token 可用 4 次
+		{"pages=5 #1", "/ptt/search?pages=5", "203.0.113.7:1234", "", 400, ""},
+		{"pages=5 #2", "/ptt/search?pages=5", "203.0.113.7:1234", "", 400, ""},
+		{"pages=5 #3", "/ptt/search?pages=5", "203.0.113.7:1234", "", 400, ""},
+		{"pages=5 #4", "/ptt/search?pages=5", "203.0.113.7:1234", "", 400, ""},
+		{"pages=5 over limit", "/ptt/search?pages=5", "203.0.113.7:1234", "", 429, "5"},
+		{"spoofed header from untrusted peer", "/ptt/search?pages=5", "203.0.113.7:1234", "198.51.100.1", 429, "5"},
+		{"other client behind traefik", "/ptt/search?pages=5", "172.18.0.2:1234", "198.51.100.1", 400, ""},
+		{"private peer outside trusted proxies", "/ptt/search?pages=5", "10.0.0.5:1234", "203.0.113.7", 400, ""},
+		// trending 預設翻 3 頁；指定 since 時依 max_pages
+		{"trending since max_pages=20", "/ptt/trending?board=../x&since=1h&max_pages=20", "198.51.100.9:1234", "", 400, ""},
+		{"trending over limit", "/ptt/trending?board=../x", "198.51.100.9:1234", "", 429, "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			w := httptest.NewRecorder()
+			mounted[r.URL.Path](w, r)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+// 成本超過 burst 的請求永遠不會通過，回 400 而不是 Retry-After 極大的 429
+func TestClientRateLimitTooExpensive(t *testing.T) {
+	original := current
+	defer Configure(original)
+	cfg := config.Default()
+	cfg.RateLimit.PerMinute = 60
+	cfg.RateLimit.Burst = 10
+	cfg.Trending.MaxPages = 20
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/ptt/trending?board=C_Chat&since=24h", nil)
+	w := httptest.NewRecorder()
+	Mount(Route{Path: "/ptt/trending", Feed: true, Cost: trendingCost, Handler: func(http.ResponseWriter, *http.Request) {
+		t.Error("expensive request reached the handler")
+	}})(w, r)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Retry-After") != "" || !strings.Contains(w.Body.String(), "request_too_expensive") {
+		t.Errorf("status = %d, Retry-After = %q: %s", w.Code, w.Header().Get("Retry-After"), w.Body.String())
+	}
+}
+
+// 文章頁等事前無法預估的上游請求在請求結束後補扣，使用者可能因此欠 token
+func TestClientRateLimitChargesUpstream(t *testing.T) {
+	original := current
+	defer Configure(original)
+	cfg := config.Default()
+	cfg.RateLimit.PerMinute = 60
+	cfg.RateLimit.Burst = 20
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	h := Mount(Route{Path: "/ptt/search", Feed: true, Handler: func(w http.ResponseWriter, r *http.Request) {
+		client := NewUpstreamClient(r)
+		for range 25 {
+			req, _ := http.NewRequestWithContext(r.Context(), "GET", upstream.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+	}})
+	for i, want := range []struct {
+		status     int
+		retryAfter string
+	}{{200, ""}, {429, "6"}} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", "/ptt/search", nil))
+		if w.Code != want.status || w.Header().Get("Retry-After") != want.retryAfter {
+			t.Errorf("request %d: status = %d, Retry-After = %q; want %d, %q", i, w.Code, w.Header().Get("Retry-After"), want.status, want.retryAfter)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Readiness is the JSON body of /ready.
+type Readiness struct {
+	// Status is "ready", "degraded" (a non-critical check failed),
+	// "not_ready" (a critical check failed) or "shutting_down"; the last two
+	// are served with 503.
+	Status string                `json:"status"`
+	Checks map[string]ReadyCheck `json:"checks"`
+}
+
+// ReadyCheck is the outcome of one dependency check.
+type ReadyCheck struct {
+	Status    string         `json:"status"` // ok 或 fail
+	Critical  bool           `json:"critical"`
+	Error     string         `json:"error,omitempty"`
+	LatencyMS int64          `json:"latency_ms"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// readinessCheck checks one dependency. Critical checks make the service
+// not ready; the others only degrade it.
+type readinessCheck struct {
+	name     string
+	critical bool
+	optional bool // only run when probing is requested, e.g. PTT
+	check    func(ctx context.Context) (map[string]any, error)
+}
+
+var readinessChecks = []readinessCheck{
+	{name: "predict_service", check: checkPredictService},
+	{name: "article_cache", critical: true, check: checkArticleCache},
+	{name: "storage", critical: true, check: checkStorage},
+	{name: "api_keys", check: checkAPIKeys},
+	{name: "image_cache", check: checkImageCache},
+	{name: "ptt", optional: true, check: checkPTT},
+}
+
+// shuttingDown makes /ready fail while the server drains. The server keeps
+// accepting requests for server.shutdown_delay afterwards, so the load
+// balancer stops sending new requests before the listener closes.
+var shuttingDown atomic.Bool
+
+// BeginShutdown marks the service as draining.
+func BeginShutdown() {
+	shuttingDown.Store(true)
+}
+
+// Cloud Functions handler. /ready is public, so probing PTT on demand
+// (?probe=ptt) requires the admin token; ready.probe_ptt probes every time.
+func GetReady(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("probe") == "ptt" {
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { serveReady(w, r, true) })(w, r)
+		return
+	}
+	serveReady(w, r, current.Ready.ProbePTT)
+}
+
+func serveReady(w http.ResponseWriter, r *http.Request, probe bool) {
+	readiness := CheckReadiness(r.Context(), probe)
+	if shuttingDown.Load() {
+		readiness.Status = "shutting_down"
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if readiness.Status == "not_ready" || readiness.Status == "shutting_down" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(readiness)
+}
+
+// CheckReadiness runs the dependency checks concurrently, each bounded by
+// ready.timeout. probe enables the optional upstream checks.
+func CheckReadiness(ctx context.Context, probe bool) Readiness {
+	readiness := Readiness{Status: "ready", Checks: make(map[string]ReadyCheck)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range readinessChecks {
+		if c.optional && !probe {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, current.Ready.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			details, err := c.check(checkCtx)
+			result := ReadyCheck{
+				Status:    "ok",
+				Critical:  c.critical,
+				LatencyMS: time.Since(start).Milliseconds(),
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			readiness.Checks[c.name] = result
+			if err != nil {
+				if c.critical {
+					readiness.Status = "not_ready"
+				} else if readiness.Status == "ready" {
+					readiness.Status = "degraded"
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return readiness
+}
+
+// checkPredictService calls the prediction service /health and verifies
+// that its model window matches predict.time_window; a mismatch makes every
+// prediction use the wrong features. Only /ptt/trending predicts, so a
+// failure degrades the service like checkPTT instead of taking search and
+// Plurk feeds out of the load balancer.
+func checkPredictService(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", PredictServiceURL+"/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newPredictClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var health struct {
+		Status      string `json:"status"`
+		ModelLoaded bool   `json:"model_loaded"`
+		TimeWindow  int    `json:"time_window"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("predict service returned %d with unreadable body: %w", resp.StatusCode, err)
+	}
+	details := map[string]any{
+		"host":                 req.URL.Host,
+		"model_loaded":         health.ModelLoaded,
+		"time_window":          health.TimeWindow,
+		"expected_time_window": predictionTimeWindow,
+	}
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		return details, fmt.Errorf("predict service returned %d (%s)", resp.StatusCode, health.Status)
+	case !health.ModelLoaded:
+		return details, errors.New("predict service has no model loaded")
+	case health.TimeWindow != predictionTimeWindow:
+		return details, fmt.Errorf("predict service time window is %d min, PREDICTION_TIME_WINDOW is %d min", health.TimeWindow, predictionTimeWindow)
+	}
+	return details, nil
+}
+
+// checkArticleCache verifies that the article cache stores and returns pages.
+func checkArticleCache(ctx context.Context) (map[string]any, error) {
+	entries, ttl := articlePages.stats()
+	details := map[string]any{"entries": entries, "ttl": ttl.String()}
+	if ttl <= 0 {
+		details["enabled"] = false
+		return details, nil
+	}
+	const key = "ready://article-cache"
+	articlePages.put(key, []byte("ok"))
+	if body, ok := articlePages.get(key); !ok || string(body) != "ok" {
+		return details, errors.New("article cache did not return a stored page")
+	}
+	return details, nil
+}
+
+// checkStorage verifies the debug record/replay directories in use. The
+// paths are logged, not reported: /ready is public.
+func checkStorage(ctx context.Context) (map[string]any, error) {
+	details := map[string]any{}
+	if dir := current.Debug.RecordDir; dir != "" {
+		details["record"] = true
+		if err := checkWritableDir(dir); err != nil {
+			slog.Warn("錄製目錄無法寫入", "dir", dir, "err", err)
+			return details, errors.New("record dir is not writable")
+		}
+	}
+	if dir := current.Debug.ReplayDir; dir != "" {
+		details["replay"] = true
+		if replayer == nil {
+			return details, errors.New("replay dir is not loaded")
+		}
+	}
+	return details, nil
+}
+
+// checkImageCache verifies that /img can write its cache. Without it every
+// image is fetched again, so the check is not critical. The directory is
+// logged, not reported.
+func checkImageCache(ctx context.Context) (map[string]any, error) {
+	details := map[string]any{"rewrite": current.ImageProxy.Rewrite}
+	if err := checkWritableDir(images.Dir()); err != nil {
+		slog.Warn("圖片快取目錄無法寫入", "dir", images.Dir(), "err", err)
+		return details, errors.New("image cache dir is not writable")
+	}
+	details["bytes"] = images.Size()
+	details["limit"] = current.ImageProxy.CacheSize
+	return details, nil
+}
+
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".ready-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkAPIKeys reports the loaded API keys. A key file that failed to
+// reload only degrades the service: the previous keys stay in use. The
+// reload error names the file and is only logged.
+func checkAPIKeys(ctx context.Context) (map[string]any, error) {
+	if keys == nil {
+		return map[string]any{"enabled": false}, nil
+	}
+	details := map[string]any{"enabled": true}
+	n, err := keys.Status()
+	details["keys"] = n
+	if err != nil {
+		return details, errors.New("key file failed to reload, the previous keys stay in use")
+	}
+	return details, nil
+}
+
+// checkPTT fetches the PTT board list. Any non-5xx answer counts as
+// reachable; PTT being down degrades the service without taking it out of
+// the load balancer, since Plurk feeds still work.
+func checkPTT(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", current.Upstream.PttBaseURL+"/bbs/index.html", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", current.Upstream.UserAgent)
+	resp, err := NewUpstreamClient(nil).Do(req)
+	if err != nil {
+		return map[string]any{"host": req.URL.Host}, err
+	}
+	resp.Body.Close()
+	details := map[string]any{"host": req.URL.Host, "status": resp.StatusCode}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return details, fmt.Errorf("ptt returned %d", resp.StatusCode)
+	}
+	return details, nil
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/auth"
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+)
+
+func TestGetReady(t *testing.T) {
+	original := current
+	defer Configure(original)
+
+	pttDown := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer pttDown.Close()
+
+	tests := []struct {
+		name           string
+		health         string
+		healthStatus   int
+		query          string
+		adminToken     string
+		expectedStatus int
+		expectedState  string
+		failedCheck    string
+		errorContains  string
+	}{
+		{
+			name:           "all dependencies ok",
+			health:         `{"status":"ok","model_loaded":true,"time_window":10,"early_window":10}`,
+			healthStatus:   200,
+			expectedStatus: 200,
+			expectedState:  "ready",
+		},
+		{
+			name:           "model window mismatch",
+			health:         `{"status":"ok","model_loaded":true,"time_window":15,"early_window":15}`,
+			healthStatus:   200,
+			expectedStatus: 200,
+			expectedState:  "degraded",
+			failedCheck:    "predict_service",
+			errorContains:  "time window is 15 min",
+		},
+		{
+			name:           "model not loaded",
+			health:         `{"status":"model_unloaded","model_loaded":false,"time_window":10,"early_window":10}`,
+			healthStatus:   503,
+			expectedStatus: 200,
+			expectedState:  "degraded",
+			failedCheck:    "predict_service",
+			errorContains:  "returned 503",
+		},
+		{
+			name:           "ptt probe failure only degrades",
+			health:         `{"status":"ok","model_loaded":true,"time_window":10,"early_window":10}`,
+			healthStatus:   200,
+			query:          "?probe=ptt",
+			adminToken:     "admin-token-0123456789",
+			expectedStatus: 200,
+			expectedState:  "degraded",
+			failedCheck:    "ptt",
+			errorContains:  "ptt returned 503",
+		},
+		{
+			name:           "ptt probe requires the admin token",
+			health:         `{"status":"ok","model_loaded":true,"time_window":10,"early_window":10}`,
+			healthStatus:   200,
+			query:          "?probe=ptt",
+			expectedStatus: 401,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			predict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.healthStatus)
+				w.Write([]byte(tt.health))
+			}))
+			defer predict.Close()
+
+			cfg := config.Default()
+			cfg.Predict.URL = predict.URL
+			cfg.Upstream.PttBaseURL = pttDown.URL
+			cfg.Auth.AdminToken = "admin-token-0123456789"
+			if err := Configure(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/ready"+tt.query, nil)
+			if tt.adminToken != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.adminToken)
+			}
+			GetReady(w, r)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.expectedStatus)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var readiness Readiness
+			if err := json.Unmarshal(w.Body.Bytes(), &readiness); err != nil {
+				t.Fatalf("invalid JSON: %v\n%s", err, w.Body.String())
+			}
+			if readiness.Status != tt.expectedState {
+				t.Errorf("readiness = %q, want %q: %s", readiness.Status, tt.expectedState, w.Body.String())
+			}
+			for name, check := range readiness.Checks {
+				wantFail := name == tt.failedCheck
+				if (check.Status == "fail") != wantFail {
+					t.Errorf("check %s = %s (%s), want fail=%v", name, check.Status, check.Error, wantFail)
+				}
+				if wantFail && !strings.Contains(check.Error, tt.errorContains) {
+					t.Errorf("check %s error = %q, want %q", name, check.Error, tt.errorContains)
+				}
+			}
+			if _, probed := readiness.Checks["ptt"]; probed != (tt.query != "") {
+				t.Errorf("ptt probed = %v, want %v", probed, tt.query != "")
+			}
+			// /ready 是公開的，不能帶出含帳密的網址
+			if strings.Contains(w.Body.String(), "http://") {
+				t.Errorf("readiness exposes a URL: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+// /ready 不需驗證，只回報 key 數量，不回報 key 檔路徑與載入錯誤內容
+func TestReadyAPIKeysHidesFile(t *testing.T) {
+	original := keys
+	defer func() { keys = original }()
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	if err := os.WriteFile(path, []byte("keys: [\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, _ = auth.Load(path, time.Minute)
+
+	details, err := checkAPIKeys(context.Background())
+	if err == nil || strings.Contains(err.Error(), path) {
+		t.Errorf("checkAPIKeys() error = %v, want a failure without the path", err)
+	}
+	if _, ok := details["file"]; ok || details["keys"] != 0 {
+		t.Errorf("checkAPIKeys() details = %v", details)
+	}
+}
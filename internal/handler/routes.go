@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/imgproxy"
+	"github.com/gorilla/feeds"
+)
+
+// Route is one endpoint of the feed tool. cmd/server mounts every route on
+// gin and init registers each one with functions-framework, so both
+// deployments share the same parameter parsing and validation. The route
+// metadata also generates the OpenAPI spec served at /openapi.json.
+type Route struct {
+	Method      string // HTTP method; empty is GET
+	Path        string // server path, e.g. /ptt/search or /f/{id}
+	Function    string // Cloud Functions entry point; empty for server-only routes
+	Summary     string
+	Description string
+	Feed        bool   // serves a feed and accepts format=rss|atom|json
+	Admin       bool   // requires the auth.admin_token bearer token
+	Signed      bool   // requires a signed URL (sig parameter), e.g. /img
+	ContentType string // response type of non-feed routes
+	Params      []Param
+	Handler     http.HandlerFunc
+
+	// Build builds the feed of a feed route without writing it, for saved
+	// feeds that serve a route under another URL. filter is the one Validate
+	// parsed from the query.
+	Build func(r *http.Request, filter *Filter) (*feeds.Feed, error)
+
+	// Cost estimates the upstream requests one call makes, charged to the
+	// client's rate limit bucket; nil costs 1.
+	Cost func(r *http.Request) float64
+}
+
+// HTTPMethod returns the method rt is served on.
+func (rt Route) HTTPMethod() string {
+	if rt.Method == "" {
+		return http.MethodGet
+	}
+	return rt.Method
+}
+
+// Routes lists the endpoints served by both the server and Cloud Functions.
+// Server-only endpoints (/health, /metrics, /debug/config) live in cmd/server.
+var Routes = []Route{
+	{
+		Path:     "/plurk/search",
+		Function: "GetPlurkSearch",
+		Summary:  "Plurk 搜尋",
+		Feed:     true,
+		Params: []Param{
+			{Name: "keyword", Type: ParamString, Required: true, Description: "搜尋關鍵字"},
+		},
+		Handler: GetPlurkSearch,
+		Build:   buildPlurkSearch,
+	},
+	{
+		Path:     "/plurk/top",
+		Function: "GetPlurkTop",
+		Summary:  "Plurk 熱門",
+		Feed:     true,
+		Params: []Param{
+			{Name: "qType", Type: ParamString, Required: true, Enum: []string{"topResponded", "hot", "favorite"},
+				Description: "topResponded (回應最多)、hot (熱門)、favorite (收藏最多)"},
+		},
+		Handler: GetPlurkTop,
+		Build:   buildPlurkTop,
+	},
+	{
+		Path:     "/ptt/search",
+		Function: "GetPttSearch",
+		Summary:  "PTT 看板搜尋",
+		Feed:     true,
+		Params: []Param{
+			{Name: "board", Type: ParamString, Required: true, Pattern: boardPattern, Description: "PTT 看板名稱，例如 C_Chat"},
+			{Name: "keyword", Type: ParamString, Description: "搜尋關鍵字"},
+			{Name: "page", Type: ParamInteger, Default: "1", Min: bound(1), Max: bound(1000), Description: "PTT 搜尋結果起始頁"},
+			{Name: "pages", Type: ParamInteger, Default: "1", Min: bound(1), Max: bound(5), Description: "從 page 開始連續抓幾頁"},
+		},
+		Handler: GetPttSearch,
+		Build:   buildPttSearch,
+		Cost:    searchCost,
+	},
+	{
+		Path:        "/ptt/trending",
+		Function:    "GetPttTrending",
+		Summary:     "PTT 熱門文章 (已爆文 + AI 預測潛在爆文)",
+		Description: "未指定的參數使用看板設定 (boards.<board>) 或 trending 區段的預設值。",
+		Feed:        true,
+		Params: []Param{
+			{Name: "board", Type: ParamString, Default: "C_Chat", Pattern: boardPattern, Description: "PTT 看板名稱"},
+			{Name: "mode", Type: ParamString, Default: "all", Enum: []string{"viral", "potential", "all", "controversial"},
+				Description: "viral (已爆文)、potential (潛在爆文)、all (兩者)、controversial (爭議文)"},
+			{Name: "threshold", Type: ParamNumber, Min: bound(0), Max: bound(1), Description: "潛在爆文的預測機率門檻"},
+			{Name: "limit", Type: ParamInteger, Min: bound(1), Max: bound(100), Description: "最多回傳幾篇"},
+			{Name: "score", Type: ParamString, Default: "push", Enum: []string{"push", "net"},
+				Description: "已爆文判斷方式: push (推文數) 或 net (推 - 噓)"},
+			{Name: "viral_pushes", Type: ParamInteger, Min: bound(1), Description: "分數達此值視為已爆文"},
+			{Name: "max_age", Type: ParamDuration, Min: bound(60), Description: "潛在爆文只看此時間內的文章"},
+			{Name: "since", Type: ParamDuration, Min: bound(60), Description: "只看此時間內的文章，往回翻頁直到超出範圍"},
+			{Name: "max_pages", Type: ParamInteger, Min: bound(1), Description: "翻頁上限，不超過 trending.max_pages"},
+		},
+		Handler: GetPttTrending,
+		Build:   buildPttTrending,
+		Cost:    trendingCost,
+	},
+	{
+		Path:        "/f/{id}",
+		Function:    "GetSavedFeed",
+		Summary:     "已儲存的 feed",
+		Description: "依 saved_feeds 或 /admin/feeds 中的定義 (來源路由、參數、篩選與格式) 產生 feed。網址上的 format 與篩選參數會覆寫定義中的同名參數。",
+		Feed:        true,
+		Params: []Param{
+			{Name: "id", In: InPath, Type: ParamString, Required: true, Description: "feed ID"},
+		},
+		Handler: GetSavedFeed,
+		Cost:    savedFeedCost,
+	},
+	{
+		Path:        "/img",
+		Function:    "GetImage",
+		Summary:     "圖片代理",
+		Description: "以磁碟快取轉送 feed 內的圖片，可縮圖。只接受由 image_proxy.rewrite 產生的簽章網址。",
+		Signed:      true,
+		ContentType: "image/*",
+		Params: []Param{
+			{Name: "url", Type: ParamString, Required: true, Pattern: `^https?://`, Description: "原始圖片網址"},
+			{Name: "w", Type: ParamInteger, Min: bound(imgproxy.MinWidth), Max: bound(imgproxy.MaxWidth), Description: "縮圖寬度 (px)，不指定則為原圖"},
+		},
+		Handler: GetImage,
+	},
+	{
+		Path:        "/ready",
+		Function:    "GetReady",
+		Summary:     "依賴檢查",
+		Description: "檢查預測服務、快取與儲存；有關鍵項目失敗或正在關閉時回 503。",
+		ContentType: "application/json",
+		Params: []Param{
+			{Name: "probe", Type: ParamString, Enum: []string{"ptt"}, Description: "另外檢查 PTT 是否可連線，需要管理 token"},
+		},
+		Handler: GetReady,
+	},
+}
+
+// Mount returns the handler to register for rt. Feed routes are rate limited
+// per client IP, charged up front by Cost and afterwards for any further
+// upstream requests they made, then accept a signed URL (sig parameter) or, when
+// auth.keys_file is set, require an API key; admin routes require the admin
+// token and signed routes a signed URL. Every route then validates its
+// parameters.
+func Mount(rt Route) http.HandlerFunc {
+	h := Validate(rt)
+	switch {
+	case rt.Admin:
+		return requireAdmin(h)
+	case rt.Feed:
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !allowClient(w, r, rt) {
+				return
+			}
+			r, upstream := withUpstreamCount(r)
+			defer chargeUpstream(r, rt, upstream)
+			switch {
+			case r.URL.Query().Has("sig"):
+				if err := signer.Verify(r.URL, Now()); err != nil {
+					writeJSONError(w, http.StatusForbidden, err.Error())
+					return
+				}
+				h(w, r)
+			case keys != nil:
+				keys.Middleware(h)(w, r)
+			default:
+				h(w, r)
+			}
+		}
+	case rt.Signed:
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := signer.Verify(r.URL, Now()); err != nil {
+				writeJSONError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			h(w, r)
+		}
+	default:
+		return h
+	}
+}
+
+// ParamError reports a missing or invalid query parameter. Route handlers
+// answer it with 400 instead of 500.
+type ParamError struct {
+	Param   string
+	Message string
+}
+
+func (e *ParamError) Error() string {
+	return "error: " + e.Message
+}
+
+// serveFeed validates the format parameter, builds the feed and writes it.
+// format is checked first so a bad request never reaches PTT or Plurk.
+func serveFeed(w http.ResponseWriter, r *http.Request, build func(*http.Request, *Filter) (*feeds.Feed, error)) {
+	if err := checkFormat(r.URL.Query()); err != nil {
+		writeError(w, err)
+		return
+	}
+	feed, err := buildFeed(r, build)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	WriteFeed(w, r, feed)
+}
+
+// buildFeed builds a feed for r with the filter Validate parsed.
+func buildFeed(r *http.Request, build func(*http.Request, *Filter) (*feeds.Feed, error)) (*feeds.Feed, error) {
+	filter, err := requestFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	return build(r, filter)
+}
+
+// writeError answers parameter errors with a structured 400 and anything
+// else with 500.
+func writeError(w http.ResponseWriter, err error) {
+	var paramErr *ParamError
+	if errors.As(err, &paramErr) {
+		writeValidationError(w, []FieldError{{Param: paramErr.Param, Message: paramErr.Message}})
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// writeJSONError answers with {"error": message}.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func checkFormat(q url.Values) error {
+	if format := q.Get("format"); format != "" {
+		if _, ok := feedContentTypes[format]; !ok {
+			return &ParamError{Param: "format", Message: "invalid format, must be one of: rss, atom, json"}
+		}
+	}
+	return nil
+}
+
+// requiredParam returns the value of name, or a ParamError when it is empty.
+func requiredParam(q url.Values, name string, message string) (string, error) {
+	value := q.Get(name)
+	if value == "" {
+		return "", &ParamError{Param: name, Message: message}
+	}
+	return value, nil
+}
+
+// enumParam returns the value of name, fallback when it is empty, or a
+// ParamError when it is not one of allowed.
+func enumParam(q url.Values, name string, fallback string, allowed ...string) (string, error) {
+	value := q.Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return value, oneOf(name, value, allowed...)
+}
+
+// oneOf returns a ParamError unless value is one of allowed.
+func oneOf(name string, value string, allowed ...string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return &ParamError{
+		Param:   name,
+		Message: fmt.Sprintf("invalid %s, must be one of: %s", name, strings.Join(allowed, ", ")),
+	}
+}
+
+// SearchOptions are the /ptt/search query parameters.
+type SearchOptions struct {
+	Board   string
+	Keyword string
+	Page    int // 起始頁 (1 ~ 1000)
+	Pages   int // 往後抓幾頁 (1 ~ 5)
+}
+
+// SearchOptionsFromQuery reads /ptt/search query parameters. page and pages
+// fall back to 1 when malformed and are clamped to their ranges.
+func SearchOptionsFromQuery(q url.Values) (SearchOptions, error) {
+	board, err := requiredParam(q, "board", "board name cannot be empty")
+	if err != nil {
+		return SearchOptions{}, err
+	}
+	return SearchOptions{
+		Board:   board,
+		Keyword: q.Get("keyword"),
+		Page:    parsePositiveInt(q.Get("page"), 1, 1, 1000),
+		Pages:   parsePositiveInt(q.Get("pages"), 1, 1, 5),
+	}, nil
+}
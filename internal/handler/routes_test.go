@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+)
+
+// 參數錯誤應回結構化的 400，且不應送出任何上游請求
+func TestRouteParamValidation(t *testing.T) {
+	var upstreamHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer upstream.Close()
+
+	original := current
+	defer Configure(original)
+	cfg := config.Default()
+	cfg.Upstream.PttBaseURL = upstream.URL
+	cfg.Upstream.PlurkBaseURL = upstream.URL
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		expected []FieldError
+	}{
+		{"ptt search without board", "/ptt/search?keyword=test",
+			[]FieldError{{Param: "board", Message: "is required"}}},
+		{"ptt search bad board", "/ptt/search?board=../etc",
+			[]FieldError{{Param: "board", Value: "../etc", Message: "must match " + boardPattern}}},
+		{"ptt search too many pages", "/ptt/search?board=C_Chat&pages=20",
+			[]FieldError{{Param: "pages", Value: "20", Message: "must be at most 5"}}},
+		{"ptt search bad format", "/ptt/search?board=C_Chat&format=xml",
+			[]FieldError{{Param: "format", Value: "xml", Message: "must be one of: rss, atom, json"}}},
+		{"trending bad mode", "/ptt/trending?board=C_Chat&mode=hot",
+			[]FieldError{{Param: "mode", Value: "hot", Message: "must be one of: viral, potential, all, controversial"}}},
+		{"trending bad numbers", "/ptt/trending?threshold=abc&limit=-5",
+			[]FieldError{
+				{Param: "threshold", Value: "abc", Message: "must be a number"},
+				{Param: "limit", Value: "-5", Message: "must be at least 1"},
+			}},
+		{"trending NaN threshold", "/ptt/trending?board=C_Chat&threshold=NaN",
+			[]FieldError{{Param: "threshold", Value: "NaN", Message: "must be a number"}}},
+		{"trending infinite threshold", "/ptt/trending?board=C_Chat&threshold=-Inf",
+			[]FieldError{{Param: "threshold", Value: "-Inf", Message: "must be a number"}}},
+		{"trending bad duration", "/ptt/trending?max_age=2days&since=10s",
+			[]FieldError{
+				{Param: "max_age", Value: "2days", Message: "must be a duration such as 90m or 2h"},
+				{Param: "since", Value: "10s", Message: "must be at least 1m0s"},
+			}},
+		{"filter bad regex", "/plurk/search?keyword=test&title_regex=%5B&min_pushes=-1",
+			[]FieldError{
+				{Param: "min_pushes", Value: "-1", Message: "must be at least 0"},
+				{Param: "title_regex", Value: "[", Message: "must be a valid regular expression: error parsing regexp: missing closing ]: `[`"},
+			}},
+		{"plurk search without keyword", "/plurk/search",
+			[]FieldError{{Param: "keyword", Message: "is required"}}},
+		{"plurk top bad qType", "/plurk/top?qType=new",
+			[]FieldError{{Param: "qType", Value: "new", Message: "must be one of: topResponded, hot, favorite"}}},
+		{"ready bad probe", "/ready?probe=plurk",
+			[]FieldError{{Param: "probe", Value: "plurk", Message: "must be one of: ptt"}}},
+	}
+
+	routes := http.NewServeMux()
+	for _, route := range Routes {
+		routes.HandleFunc(route.Path, Validate(route))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+			var body ValidationError
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON: %v\n%s", err, w.Body.String())
+			}
+			if len(body.Errors) != len(tt.expected) {
+				t.Fatalf("errors = %+v, want %+v", body.Errors, tt.expected)
+			}
+			for i, e := range tt.expected {
+				if body.Errors[i] != e {
+					t.Errorf("errors[%d] = %+v, want %+v", i, body.Errors[i], e)
+				}
+			}
+		})
+	}
+	if n := upstreamHits.Load(); n != 0 {
+		t.Errorf("invalid requests made %d upstream requests", n)
+	}
+}
+
+// 設定 auth.keys_file 後只有 feed 路由需要 API key
+func TestMountRequiresKeyOnFeedRoutes(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.yaml")
+	os.WriteFile(keysFile, []byte("keys:\n  - name: reader\n    key: reader-key-0123456789\n"), 0o600)
+
+	original := current
+	defer Configure(original)
+	cfg := config.Default()
+	cfg.Auth.KeysFile = keysFile
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := http.NewServeMux()
+	for _, route := range Routes {
+		routes.HandleFunc(route.Path, Mount(route))
+	}
+
+	tests := []struct {
+		url            string
+		expectedStatus int
+	}{
+		{"/ptt/search?board=C_Chat", 401},
+		{"/plurk/top?qType=hot&key=wrong-key-0123456789", 401},
+		// key 正確後才檢查參數
+		{"/ptt/search?key=reader-key-0123456789", 400},
+		{"/ready?probe=plurk", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+// 沒有經過 Validate 時 (直接呼叫 handler)，handler 本身仍會拒絕缺少的必要參數
+func TestHandlerParamErrors(t *testing.T) {
+	w := httptest.NewRecorder()
+	GetPttSearch(w, httptest.NewRequest("GET", "/ptt/search?keyword=test", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+	var body ValidationError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Errors) != 1 || body.Errors[0].Param != "board" {
+		t.Errorf("body = %s, want board error", w.Body.String())
+	}
+}
+
+func TestSearchOptionsFromQuery(t *testing.T) {
+	tests := []struct {
+		query         string
+		expectedPage  int
+		expectedPages int
+	}{
+		{"board=C_Chat", 1, 1},
+		{"board=C_Chat&page=3&pages=2", 3, 2},
+		{"board=C_Chat&page=abc&pages=20", 1, 5},
+		{"board=C_Chat&page=0&pages=-1", 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ptt/search?"+tt.query, nil)
+			opts, err := SearchOptionsFromQuery(r.URL.Query())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.Page != tt.expectedPage || opts.Pages != tt.expectedPages {
+				t.Errorf("page/pages = %d/%d, want %d/%d", opts.Page, opts.Pages, tt.expectedPage, tt.expectedPages)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/Harrison-Dev/go_feed_tool/internal/savedfeed"
+)
+
+// maxSavedFeedBody bounds the JSON body of /admin/feeds requests.
+const maxSavedFeedBody = 64 << 10
+
+// savedFeeds holds the feeds served at /f/{id}.
+var savedFeeds = loadSavedFeeds(current)
+
+// sources are the feed routes a saved feed can point at, by path. They are
+// collected in init because Routes itself refers to GetSavedFeed.
+var sources map[string]Route
+
+func init() {
+	sources = make(map[string]Route)
+	for _, rt := range Routes {
+		if rt.Build != nil {
+			sources[rt.Path] = rt
+		}
+	}
+}
+
+func loadSavedFeeds(cfg *config.Config) *savedfeed.Store {
+	s, err := savedfeed.Open(cfg.SavedFeeds.File, cfg.SavedFeeds.Feeds)
+	if err != nil {
+		slog.Warn("saved feed 檔載入失敗，只提供設定檔中的 feed", "file", cfg.SavedFeeds.File, "err", err)
+		s, _ = savedfeed.Open("", cfg.SavedFeeds.Feeds)
+	}
+	return s
+}
+
+// openSavedFeeds checks the feeds of the config file against the route
+// table and opens the store of cfg.
+func openSavedFeeds(cfg *config.Config) (*savedfeed.Store, error) {
+	var errs []error
+	for i, f := range cfg.SavedFeeds.Feeds {
+		if err := checkSavedFeed(f, cfg); err != nil {
+			errs = append(errs, fmt.Errorf("saved_feeds.feeds[%d] (%s): %w", i, f.ID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return savedfeed.Open(cfg.SavedFeeds.File, cfg.SavedFeeds.Feeds)
+}
+
+// checkSavedFeed validates each source of f like a request to its route,
+// and also rejects parameters the route does not accept, which are typos
+// more often than not. With rate limiting on, a feed costing more than
+// rate_limit.burst of cfg is rejected too: no fetch of it could pass.
+func checkSavedFeed(f savedfeed.Feed, cfg *config.Config) error {
+	if err := checkSavedFeedSources(f); err != nil {
+		return err
+	}
+	if cfg.RateLimit.PerMinute <= 0 {
+		return nil
+	}
+	r := withConfig(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/f/" + f.ID}}, cfg)
+	if cost := feedCost(r, f); cost > float64(cfg.RateLimit.Burst) {
+		param := "params"
+		if f.Composite() {
+			param = "sources"
+		}
+		return &ParamError{Param: param, Message: fmt.Sprintf("the feed costs %g upstream requests per fetch, more than rate_limit.burst (%d)", cost, cfg.RateLimit.Burst)}
+	}
+	return nil
+}
+
+func checkSavedFeedSources(f savedfeed.Feed) error {
+	if err := savedfeed.CheckID(f.ID); err != nil {
+		return &ParamError{Param: "id", Message: err.Error()}
+	}
+	if !f.Composite() {
+		if f.Limit != 0 {
+			return &ParamError{Param: "limit", Message: "limit only applies to composite feeds, set the limit parameter in params"}
+		}
+		return checkSource("", savedfeed.Source{Source: f.Source, Params: f.Params}, f.Format)
+	}
+	switch {
+	case f.Source != "" || len(f.Params) > 0:
+		return &ParamError{Param: "sources", Message: "a composite feed sets source and params on each of its sources"}
+	case len(f.Sources) > maxCompositeSources:
+		return &ParamError{Param: "sources", Message: fmt.Sprintf("a composite feed has at most %d sources", maxCompositeSources)}
+	case f.Limit < 0 || f.Limit > maxCompositeLimit:
+		return &ParamError{Param: "limit", Message: fmt.Sprintf("limit must be between 0 and %d", maxCompositeLimit)}
+	}
+	for i, src := range f.Sources {
+		if err := checkSource(fmt.Sprintf("sources[%d].", i), src, f.Format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSource validates one source of a saved feed. prefix locates it in
+// the definition for error messages, e.g. "sources[1].".
+func checkSource(prefix string, src savedfeed.Source, format string) error {
+	rt, ok := sources[src.Source]
+	if !ok {
+		return &ParamError{Param: prefix + "source", Message: fmt.Sprintf("%s is not a feed route", src.Source)}
+	}
+	accepted := make(map[string]bool)
+	for _, p := range rt.AllParams() {
+		accepted[p.Name] = true
+	}
+	var unknown []string
+	for name := range src.Params {
+		if name == "format" || !accepted[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return &ParamError{Param: prefix + "params", Message: fmt.Sprintf("%s does not accept %s (set format in the format field)", src.Source, strings.Join(unknown, ", "))}
+	}
+	if errs := rt.CheckParams(src.Query(format)); len(errs) > 0 {
+		return paramsError(prefix+"params", errs)
+	}
+	return nil
+}
+
+// paramsError folds the errors of a nested set of parameters, e.g. the
+// query of a URL being signed, into one ParamError of param.
+func paramsError(param string, errs []FieldError) error {
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, e.Param+" "+e.Message)
+	}
+	return &ParamError{Param: param, Message: strings.Join(msgs, "; ")}
+}
+
+// pathID returns the {id} path parameter of r. gin and Cloud Functions do
+// not set path values, so it falls back to the last path segment.
+func pathID(r *http.Request) string {
+	if id := r.PathValue("id"); id != "" {
+		return id
+	}
+	return path.Base(r.URL.Path)
+}
+
+// GetSavedFeed handles GET /f/{id}
+func GetSavedFeed(w http.ResponseWriter, r *http.Request) {
+	f, ok := savedFeeds.Get(pathID(r))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, savedfeed.ErrNotFound.Error())
+		return
+	}
+	if f.Composite() {
+		serveComposite(w, r, f)
+		return
+	}
+	rt, req, err := sourceRequest(r, f.Source, f.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	feed, err := buildFeed(req, rt.Build)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if f.Title != "" {
+		feed.Title = f.Title
+	}
+	WriteFeed(w, req, feed)
+}
+
+// sourceRequest returns the route serving source and a copy of r asking it
+// for q. format and the filter parameters of r override those of q.
+func sourceRequest(r *http.Request, source string, q url.Values) (Route, *http.Request, error) {
+	rt, ok := sources[source]
+	if !ok {
+		return Route{}, nil, fmt.Errorf("error: %s is not a feed route", source)
+	}
+	for _, p := range append([]Param{formatParam}, filterParams...) {
+		if values := r.URL.Query()[p.Name]; len(values) > 0 {
+			q[p.Name] = values
+		}
+	}
+	filter, errs := rt.parseParams(q)
+	if len(errs) > 0 {
+		return Route{}, nil, paramsError("params", errs)
+	}
+	req := withFilter(r.Clone(r.Context()), filter)
+	req.URL.Path = source
+	req.URL.RawQuery = q.Encode()
+	return rt, req, nil
+}
+
+// savedFeedCost is the cost of the source routes of the saved feed.
+func savedFeedCost(r *http.Request) float64 {
+	f, ok := savedFeeds.Get(pathID(r))
+	if !ok {
+		return 1
+	}
+	return feedCost(r, f)
+}
+
+func feedCost(r *http.Request, f savedfeed.Feed) float64 {
+	if !f.Composite() {
+		return sourceCost(r, f.Source, f.Query())
+	}
+	var cost float64
+	for _, src := range f.Sources {
+		cost += sourceCost(r, src.Source, src.Query(f.Format))
+	}
+	return cost
+}
+
+func sourceCost(r *http.Request, source string, q url.Values) float64 {
+	rt, req, err := sourceRequest(r, source, q)
+	if err != nil || rt.Cost == nil {
+		return 1
+	}
+	return rt.Cost(req)
+}
+
+// SavedFeed is the JSON body of the /admin/feeds routes.
+type SavedFeed struct {
+	savedfeed.Feed
+	URL string `json:"url"` // 訂閱網址，例如 /f/cchat-hot
+}
+
+func savedFeedJSON(f savedfeed.Feed) SavedFeed {
+	return SavedFeed{Feed: f, URL: "/f/" + f.ID}
+}
+
+// GetAdminFeeds handles GET /admin/feeds
+func GetAdminFeeds(w http.ResponseWriter, r *http.Request) {
+	list := []SavedFeed{}
+	for _, f := range savedFeeds.List() {
+		list = append(list, savedFeedJSON(f))
+	}
+	writeAdminJSON(w, http.StatusOK, list)
+}
+
+// GetAdminFeed handles GET /admin/feeds/{id}
+func GetAdminFeed(w http.ResponseWriter, r *http.Request) {
+	f, ok := savedFeeds.Get(pathID(r))
+	if !ok {
+		writeSavedFeedError(w, savedfeed.ErrNotFound)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, savedFeedJSON(f))
+}
+
+// PostAdminFeeds handles POST /admin/feeds. Without an id in the body the
+// feed gets a random one.
+func PostAdminFeeds(w http.ResponseWriter, r *http.Request) {
+	f, err := readSavedFeed(w, r)
+	if err != nil {
+		writeSavedFeedError(w, err)
+		return
+	}
+	random := f.ID == ""
+	for attempt := 0; ; attempt++ {
+		if random {
+			f.ID = savedfeed.NewID()
+		}
+		if err = checkSavedFeed(f, current); err == nil {
+			err = savedFeeds.Create(f)
+		}
+		// 隨機 ID 重複的機率極低，重試幾次即可
+		if !random || !errors.Is(err, savedfeed.ErrExists) || attempt == 2 {
+			break
+		}
+	}
+	if err != nil {
+		writeSavedFeedError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/admin/feeds/"+f.ID)
+	writeAdminJSON(w, http.StatusCreated, savedFeedJSON(f))
+}
+
+// PutAdminFeed handles PUT /admin/feeds/{id}, creating or replacing the
+// feed. Subscribers of /f/{id} get the new definition on their next fetch.
+func PutAdminFeed(w http.ResponseWriter, r *http.Request) {
+	f, err := readSavedFeed(w, r)
+	if err != nil {
+		writeSavedFeedError(w, err)
+		return
+	}
+	id := pathID(r)
+	if f.ID != "" && f.ID != id {
+		writeSavedFeedError(w, &ParamError{Param: "id", Message: "id in the body does not match the URL"})
+		return
+	}
+	f.ID = id
+	if err := checkSavedFeed(f, current); err != nil {
+		writeSavedFeedError(w, err)
+		return
+	}
+	created, err := savedFeeds.Put(f)
+	if err != nil {
+		writeSavedFeedError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeAdminJSON(w, status, savedFeedJSON(f))
+}
+
+// DeleteAdminFeed handles DELETE /admin/feeds/{id}
+func DeleteAdminFeed(w http.ResponseWriter, r *http.Request) {
+	if err := savedFeeds.Delete(pathID(r)); err != nil {
+		writeSavedFeedError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readSavedFeed decodes the JSON body of r. Unknown fields are rejected so
+// a misspelled field is not silently dropped.
+func readSavedFeed(w http.ResponseWriter, r *http.Request) (savedfeed.Feed, error) {
+	var f savedfeed.Feed
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSavedFeedBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return f, &ParamError{Param: "body", Message: "invalid JSON: " + err.Error()}
+	}
+	f.ReadOnly = false
+	return f, nil
+}
+
+// writeSavedFeedError answers store errors with 404 or 409, parameter
+// errors with 400 and anything else, e.g. a failed file write, with 500.
+func writeSavedFeedError(w http.ResponseWriter, err error) {
+	var paramErr *ParamError
+	switch {
+	case errors.As(err, &paramErr):
+		writeError(w, err)
+	case errors.Is(err, savedfeed.ErrNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, savedfeed.ErrExists), errors.Is(err, savedfeed.ErrReadOnly), errors.Is(err, savedfeed.ErrNoFile):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("saved feed 寫入失敗", "file", savedFeeds.Path(), "err", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/Harrison-Dev/go_feed_tool/internal/savedfeed"
+)
+
+func TestAdminFeeds(t *testing.T) {
+	original := current
+	defer Configure(original)
+	file := filepath.Join(t.TempDir(), "feeds.json")
+	cfg := config.Default()
+	cfg.Auth.AdminToken = testAdminToken
+	cfg.Auth.SigningSecrets = []string{"signing-secret-0123456789"}
+	cfg.SavedFeeds.File = file
+	cfg.SavedFeeds.Feeds = []savedfeed.Feed{{ID: "cchat", Source: "/ptt/trending", Params: map[string]string{"board": "C_Chat"}}}
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := http.NewServeMux()
+	for _, route := range AdminRoutes {
+		routes.HandleFunc(route.HTTPMethod()+" "+route.Path, Mount(route))
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+	}{
+		{"create", "PUT", "/admin/feeds/hot", `{"source":"/plurk/top","params":{"qType":"hot","exclude":"業配"},"format":"atom"}`, 201},
+		{"replace", "PUT", "/admin/feeds/hot", `{"title":"噗浪熱門","source":"/plurk/top","params":{"qType":"hot"}}`, 200},
+		{"random id", "POST", "/admin/feeds", `{"source":"/ptt/search","params":{"board":"C_Chat","keyword":"芙莉蓮"}}`, 201},
+		{"duplicate id", "POST", "/admin/feeds", `{"id":"hot","source":"/plurk/top","params":{"qType":"hot"}}`, 409},
+		{"config feed is read-only", "PUT", "/admin/feeds/cchat", `{"source":"/plurk/top","params":{"qType":"hot"}}`, 409},
+		{"unknown source", "PUT", "/admin/feeds/x", `{"source":"/ready"}`, 400},
+		{"missing required param", "PUT", "/admin/feeds/x", `{"source":"/ptt/search"}`, 400},
+		{"misspelled param", "PUT", "/admin/feeds/x", `{"source":"/ptt/search","params":{"board":"C_Chat","keywrod":"a"}}`, 400},
+		{"bad format", "PUT", "/admin/feeds/x", `{"source":"/plurk/top","params":{"qType":"hot"},"format":"xml"}`, 400},
+		{"unknown field", "PUT", "/admin/feeds/x", `{"source":"/plurk/top","parms":{"qType":"hot"}}`, 400},
+		{"id mismatch", "PUT", "/admin/feeds/x", `{"id":"y","source":"/plurk/top","params":{"qType":"hot"}}`, 400},
+		{"composite", "PUT", "/admin/feeds/team", `{"sources":[{"source":"/ptt/trending","params":{"board":"C_Chat"}},{"source":"/plurk/top","params":{"qType":"hot"}}],"limit":30}`, 201},
+		{"composite with source", "PUT", "/admin/feeds/x", `{"source":"/plurk/top","sources":[{"source":"/plurk/top","params":{"qType":"hot"}}]}`, 400},
+		{"composite bad source", "PUT", "/admin/feeds/x", `{"sources":[{"source":"/plurk/top","params":{"qType":"hot"}},{"source":"/ptt/search"}]}`, 400},
+		{"composite limit too high", "PUT", "/admin/feeds/x", `{"sources":[{"source":"/plurk/top","params":{"qType":"hot"}}],"limit":1000}`, 400},
+		{"limit on single source", "PUT", "/admin/feeds/x", `{"source":"/plurk/top","params":{"qType":"hot"},"limit":10}`, 400},
+		{"get", "GET", "/admin/feeds/hot", "", 200},
+		{"get missing", "GET", "/admin/feeds/x", "", 404},
+		{"delete", "DELETE", "/admin/feeds/hot", "", 204},
+		{"delete missing", "DELETE", "/admin/feeds/hot", "", 404},
+		{"delete config feed", "DELETE", "/admin/feeds/cchat", "", 409},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+testAdminToken)
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if tt.name == "replace" {
+				var got SavedFeed
+				json.Unmarshal(w.Body.Bytes(), &got)
+				if got.URL != "/f/hot" || got.Title != "噗浪熱門" || got.Format != "" {
+					t.Errorf("body = %+v", got)
+				}
+			}
+		})
+	}
+
+	// 清單含設定檔中的 feed 與隨機 ID 的 feed
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/feeds", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	routes.ServeHTTP(w, req)
+	var list []SavedFeed
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("list = %+v, want cchat, team and the random feed", list)
+	}
+	if data, _ := os.ReadFile(file); strings.Contains(string(data), "cchat") || !strings.Contains(string(data), "芙莉蓮") {
+		t.Errorf("feeds file:\n%s", data)
+	}
+
+	// 已儲存的 feed 可簽章，不存在的不行
+	if _, err := SignFeedURL("/f/cchat?format=json", time.Hour); err != nil {
+		t.Errorf("SignFeedURL(/f/cchat) = %v", err)
+	}
+	if _, err := SignFeedURL("/f/missing", time.Hour); err == nil {
+		t.Error("SignFeedURL signed a missing saved feed")
+	}
+}
+
+func TestConfigureRejectsInvalidSavedFeeds(t *testing.T) {
+	original := current
+	defer Configure(original)
+	cfg := config.Default()
+	cfg.SavedFeeds.Feeds = []savedfeed.Feed{{ID: "bad", Source: "/ptt/trending", Params: map[string]string{"mode": "hot"}}}
+	err := Configure(cfg)
+	if err == nil || !strings.Contains(err.Error(), "saved_feeds.feeds[0] (bad)") {
+		t.Errorf("Configure() = %v, want saved feed error", err)
+	}
+}
+
+// 組合 feed 的總成本超過 burst 時，每次抓取都會被拒絕，定義時就擋下
+func TestCheckSavedFeedBurst(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimit.PerMinute = 60
+	cfg.RateLimit.Burst = 40
+	trending := savedfeed.Source{Source: "/ptt/trending", Params: map[string]string{"board": "C_Chat", "mode": "potential"}}
+
+	tests := []struct {
+		name    string
+		feed    savedfeed.Feed
+		wantErr bool
+	}{
+		{"within burst", savedfeed.Feed{ID: "two", Sources: []savedfeed.Source{trending, trending}}, false},
+		{"over burst", savedfeed.Feed{ID: "three", Sources: []savedfeed.Source{trending, trending, trending}}, true},
+		{"single source", savedfeed.Feed{ID: "one", Source: trending.Source, Params: trending.Params}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSavedFeed(tt.feed, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkSavedFeed() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "rate_limit.burst (40)") {
+				t.Errorf("checkSavedFeed() = %v, want burst in message", err)
+			}
+		})
+	}
+
+	// 不限流時不檢查成本
+	cfg.RateLimit.PerMinute = 0
+	if err := checkSavedFeed(tests[1].feed, cfg); err != nil {
+		t.Errorf("checkSavedFeed() without rate limit = %v", err)
+	}
+
+	// 成本以受檢查的設定計算，而非目前生效的設定
+	cfg = config.Default()
+	cfg.RateLimit.PerMinute = 60
+	cfg.RateLimit.Burst = 10
+	cfg.Trending.MaxPages = 10
+	if err := checkSavedFeed(tests[2].feed, cfg); err != nil {
+		t.Errorf("checkSavedFeed() with max_pages 10 = %v", err)
+	}
+}
+
+func TestSourceRequest(t *testing.T) {
+	f := savedfeed.Feed{
+		ID:     "hot",
+		Source: "/ptt/search",
+		Params: map[string]string{"board": "C_Chat", "keyword": "芙莉蓮", "exclude_tag": "公告"},
+		Format: "atom",
+	}
+	r := httptest.NewRequest("GET", "/f/hot?format=json&exclude_tag=問卦&board=Gossiping&key=reader-key-0123456789", nil)
+	rt, req, err := sourceRequest(r, f.Source, f.Query())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.Path != "/ptt/search" || req.URL.Path != "/ptt/search" {
+		t.Errorf("route = %s, path = %s", rt.Path, req.URL.Path)
+	}
+	// format 與篩選參數可覆寫，來源參數不行；API key 不會傳給來源
+	want := url.Values{"board": {"C_Chat"}, "keyword": {"芙莉蓮"}, "exclude_tag": {"問卦"}, "format": {"json"}}
+	if got := req.URL.Query(); got.Encode() != want.Encode() {
+		t.Errorf("query = %s, want %s", got.Encode(), want.Encode())
+	}
+}
@@ -0,0 +1,227 @@
+// Package httprec records upstream HTTP exchanges to a directory and replays
+// them later, so a feed that broke on new PTT markup can be reproduced
+// locally and its pages turned into fakeupstream fixtures.
+//
+// Each exchange is stored as two files: NNNN.json with the request and
+// response metadata, and NNNN.body with the raw response body.
+package httprec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Exchange is the metadata of one recorded request/response pair.
+type Exchange struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	BodyFile    string      `json:"body_file,omitempty"`
+	Error       string      `json:"error,omitempty"` // transport error, replayed as an error
+}
+
+func (e *Exchange) key() string {
+	return exchangeKey(e.Method, e.URL, e.RequestBody)
+}
+
+func exchangeKey(method string, url string, body string) string {
+	return method + " " + url + "\n" + body
+}
+
+// Recorder is an http.RoundTripper that passes requests to Transport and
+// writes every exchange to Dir.
+type Recorder struct {
+	Dir       string
+	Transport http.RoundTripper
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder returns a Recorder writing to dir; a nil transport means
+// http.DefaultTransport. The directory is created on the first exchange.
+func NewRecorder(dir string, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{Dir: dir, Transport: transport}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	exchange := Exchange{Method: req.Method, URL: req.URL.String(), RequestBody: reqBody}
+	resp, rtErr := r.Transport.RoundTrip(req)
+	if rtErr != nil {
+		exchange.Error = rtErr.Error()
+		r.write(&exchange, nil)
+		return nil, rtErr
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	exchange.Status = resp.StatusCode
+	exchange.Header = resp.Header.Clone()
+	r.write(&exchange, body)
+	return resp, nil
+}
+
+// write stores the exchange; failures are reported but never break the
+// request being recorded.
+func (r *Recorder) write(exchange *Exchange, body []byte) {
+	r.mu.Lock()
+	r.seq++
+	seq := r.seq
+	r.mu.Unlock()
+
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		slog.Warn("錄製失敗", "dir", r.Dir, "err", err)
+		return
+	}
+	name := fmt.Sprintf("%04d", seq)
+	if body != nil {
+		exchange.BodyFile = name + ".body"
+		if err := os.WriteFile(filepath.Join(r.Dir, exchange.BodyFile), body, 0o644); err != nil {
+			slog.Warn("錄製失敗", "dir", r.Dir, "err", err)
+			return
+		}
+	}
+	meta, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		slog.Warn("錄製失敗", "dir", r.Dir, "err", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, name+".json"), meta, 0o644); err != nil {
+		slog.Warn("錄製失敗", "dir", r.Dir, "err", err)
+	}
+}
+
+// Replayer is an http.RoundTripper that answers requests from a directory
+// written by Recorder. Requests are matched on method, full URL and request
+// body; repeated requests are answered in recorded order, reusing the last
+// one when exhausted. Unmatched requests fail instead of going upstream.
+type Replayer struct {
+	dir string
+
+	mu        sync.Mutex
+	exchanges map[string][]*Exchange
+}
+
+// ErrNotRecorded is returned for requests missing from the recording.
+var ErrNotRecorded = errors.New("httprec: request not recorded")
+
+// NewReplayer loads the exchanges recorded in dir.
+func NewReplayer(dir string) (*Replayer, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("httprec: no exchanges recorded in %s", dir)
+	}
+	sort.Strings(names)
+
+	r := &Replayer{dir: dir, exchanges: make(map[string][]*Exchange)}
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(data, &exchange); err != nil {
+			return nil, fmt.Errorf("httprec: %s: %w", name, err)
+		}
+		r.exchanges[exchange.key()] = append(r.exchanges[exchange.key()], &exchange)
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	key := exchangeKey(req.Method, req.URL.String(), reqBody)
+	queue := r.exchanges[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, req.URL)
+	}
+	exchange := queue[0]
+	if len(queue) > 1 {
+		r.exchanges[key] = queue[1:]
+	}
+	r.mu.Unlock()
+
+	if exchange.Error != "" {
+		return nil, errors.New(exchange.Error)
+	}
+
+	var body []byte
+	if exchange.BodyFile != "" {
+		body, err = os.ReadFile(filepath.Join(r.dir, exchange.BodyFile))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+		StatusCode:    exchange.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        exchange.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody returns the request body as a string and restores it so
+// the request can still be sent.
+func readRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return string(body), nil
+}
+
+// SessionDir returns a fresh subdirectory name of root for one inbound
+// request, e.g. root/20260122-200000.000-ptt-trending.
+func SessionDir(root string, stamp string, route string) string {
+	route = strings.Trim(strings.ReplaceAll(route, "/", "-"), "-")
+	if route == "" {
+		route = "root"
+	}
+	return filepath.Join(root, stamp+"-"+route)
+}
@@ -0,0 +1,73 @@
+package httprec
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		r.ParseForm()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("page " + r.URL.Path + " " + r.PostForm.Get("query")))
+	}))
+
+	dir := t.TempDir()
+	recording := &http.Client{Transport: NewRecorder(dir, nil)}
+	get(t, recording, server.URL+"/bbs/C_Chat/index.html")
+	post(t, recording, server.URL+"/Search/search2", "台灣")
+	server.Close()
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer() error = %v", err)
+	}
+	replaying := &http.Client{Transport: replayer}
+
+	if body := get(t, replaying, server.URL+"/bbs/C_Chat/index.html"); body != "page /bbs/C_Chat/index.html " {
+		t.Errorf("replayed GET body = %q", body)
+	}
+	if body := post(t, replaying, server.URL+"/Search/search2", "台灣"); body != "page /Search/search2 台灣" {
+		t.Errorf("replayed POST body = %q", body)
+	}
+	// repeated requests reuse the last recording
+	get(t, replaying, server.URL+"/bbs/C_Chat/index.html")
+
+	if calls != 2 {
+		t.Errorf("upstream calls = %d, want 2", calls)
+	}
+
+	_, err = replaying.Get(server.URL + "/bbs/C_Chat/index2.html")
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("unrecorded request error = %v, want ErrNotRecorded", err)
+	}
+}
+
+func get(t *testing.T, client *http.Client, u string) string {
+	t.Helper()
+	resp, err := client.Get(u)
+	if err != nil {
+		t.Fatalf("GET %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func post(t *testing.T, client *http.Client, u string, query string) string {
+	t.Helper()
+	resp, err := client.Post(u, "application/x-www-form-urlencoded", strings.NewReader(url.Values{"query": {query}}.Encode()))
+	if err != nil {
+		t.Fatalf("POST %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
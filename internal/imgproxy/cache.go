@@ -0,0 +1,161 @@
+package imgproxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cache stores images on disk, one file per key holding the content type on
+// the first line followed by the image. When the files exceed the size
+// limit the least recently used ones are removed. It is safe for concurrent
+// use, also by several processes sharing the directory.
+type Cache struct {
+	dir   string
+	limit int64
+
+	mu   sync.Mutex
+	size int64 // -1 表示尚未掃描目錄
+}
+
+// NewCache returns a cache in dir holding at most limit bytes. dir is
+// created on the first Put.
+func NewCache(dir string, limit int64) *Cache {
+	return &Cache{dir: dir, limit: limit, size: -1}
+}
+
+// Dir returns the cache directory.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Key returns the cache key of src resized to width (0 for the original).
+func Key(src string, width int) string {
+	sum := sha256.Sum256([]byte(src + "\n" + strconv.Itoa(width)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached image of key and marks it as recently used.
+func (c *Cache) Get(key string) (Image, bool) {
+	file := c.path(key)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Image{}, false
+	}
+	contentType, body, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return Image{}, false
+	}
+	now := time.Now()
+	os.Chtimes(file, now, now)
+	return Image{ContentType: string(contentType), Data: body}, true
+}
+
+// Put stores img under key, then evicts the least recently used images
+// while the cache is over its limit.
+func (c *Cache) Put(key string, img Image) error {
+	file := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	// 先寫暫存檔再改名，讀取端不會看到寫到一半的檔案
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append([]byte(img.ContentType+"\n"), img.Data...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size < 0 {
+		c.size = c.scan()
+	} else {
+		c.size += int64(len(img.ContentType) + 1 + len(img.Data))
+	}
+	if c.size > c.limit {
+		c.evict()
+	}
+	return nil
+}
+
+// Size returns the bytes stored in the cache directory.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size < 0 {
+		c.size = c.scan()
+	}
+	return c.size
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+type cachedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *Cache) files() []cachedFile {
+	var files []cachedFile
+	filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files = append(files, cachedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+	return files
+}
+
+func (c *Cache) scan() int64 {
+	var size int64
+	for _, f := range c.files() {
+		size += f.size
+	}
+	return size
+}
+
+// evict removes the least recently used files until the cache is at 90% of
+// its limit, so a full cache does not rescan the directory on every Put.
+// The directory is rescanned because other processes may share it.
+func (c *Cache) evict() {
+	files := c.files()
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	c.size = 0
+	for _, f := range files {
+		c.size += f.size
+	}
+	target := c.limit / 10 * 9
+	for _, f := range files {
+		if c.size <= target {
+			return
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		c.size -= f.size
+	}
+}
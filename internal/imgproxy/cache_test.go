@@ -0,0 +1,60 @@
+package imgproxy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheGetPut(t *testing.T) {
+	c := NewCache(filepath.Join(t.TempDir(), "img"), 1<<20)
+	key := Key("https://i.imgur.com/abc123.jpg", 0)
+	if _, ok := c.Get(key); ok {
+		t.Fatal("empty cache returned an image")
+	}
+	img := Image{ContentType: "image/jpeg", Data: []byte("\xff\xd8\xff\nbinary\n")}
+	if err := c.Put(key, img); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c.Get(key)
+	if !ok || got.ContentType != img.ContentType || !bytes.Equal(got.Data, img.Data) {
+		t.Fatalf("Get = %+v, %v; want %+v", got, ok, img)
+	}
+	if Key("https://i.imgur.com/abc123.jpg", 320) == key {
+		t.Error("thumbnail widths must have their own key")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(t.TempDir(), 250)
+	data := Image{ContentType: "image/png", Data: bytes.Repeat([]byte("x"), 90)}
+	keys := []string{Key("a", 0), Key("b", 0), Key("c", 0)}
+
+	old := time.Now().Add(-time.Hour)
+	for i, key := range keys[:2] {
+		if err := c.Put(key, data); err != nil {
+			t.Fatal(err)
+		}
+		// a 最久未用
+		mtime := old.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(c.path(key), mtime, mtime)
+	}
+	c.Get(keys[0]) // 讀取後 a 變成最近使用
+	if err := c.Put(keys[2], data); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Get(keys[1]); ok {
+		t.Error("least recently used image was not evicted")
+	}
+	for _, key := range []string{keys[0], keys[2]} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("image %s was evicted", key[:8])
+		}
+	}
+	if size := c.Size(); size > 250 {
+		t.Errorf("size = %d, over the limit", size)
+	}
+}
@@ -0,0 +1,188 @@
+// Package imgproxy fetches, resizes and caches the images served by /img.
+// Feed readers often cannot load imgur hotlinks or http images on https
+// pages, and Plurk image URLs expire, so feeds can point their images at the
+// feed tool instead.
+package imgproxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // 註冊 GIF 解碼器
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Thumbnail widths accepted by Thumbnail.
+const (
+	MinWidth = 16
+	MaxWidth = 2048
+)
+
+// maxPixels rejects images that would take too much memory to decode, e.g.
+// a small PNG declaring 50000x50000 pixels.
+const maxPixels = 40_000_000
+
+// jpegQuality is the quality of JPEG thumbnails.
+const jpegQuality = 85
+
+var (
+	// ErrTooLarge is returned for images over the byte or pixel limit.
+	ErrTooLarge = errors.New("image exceeds the size limit")
+	// ErrNotImage is returned when upstream does not answer with an image.
+	ErrNotImage = errors.New("upstream response is not an image")
+	// ErrPrivateAddress is returned by PublicDialer for non-public hosts.
+	ErrPrivateAddress = errors.New("image host is not a public address")
+)
+
+// Image is an image and its content type.
+type Image struct {
+	ContentType string
+	Data        []byte
+}
+
+// Fetch downloads the image at src with client, reading at most maxBytes.
+// SVG is refused: served from the feed tool's origin its scripts would run
+// there.
+func Fetch(ctx context.Context, client *http.Client, src string, maxBytes int64, userAgent string) (Image, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return Image{}, err
+	}
+	// 不帶 Referer，imgur 等圖床會擋外站引用
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "image/*")
+	resp, err := client.Do(req)
+	if err != nil {
+		return Image{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Image{}, fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxBytes {
+		return Image{}, ErrTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return Image{}, err
+	}
+	if int64(len(data)) > maxBytes {
+		return Image{}, ErrTooLarge
+	}
+
+	// 以內容判斷格式，不信任上游的 Content-Type
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") || contentType == "image/svg+xml" {
+		return Image{}, ErrNotImage
+	}
+	return Image{ContentType: contentType, Data: data}, nil
+}
+
+// PublicDialer returns a dialer that refuses loopback, private and
+// link-local addresses. Image URLs come from article bodies, so an article
+// could otherwise make /img fetch from the local network or the cloud
+// metadata server. The check runs on the resolved address and so also
+// covers DNS names and redirects.
+func PublicDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublic(addr.Addr()) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+}
+
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// Thumbnail scales img down to width, keeping its aspect ratio. JPEG stays
+// JPEG; PNG and GIF become PNG, which keeps transparency but only the first
+// frame of an animation. Images already narrower than width and formats
+// without a standard library decoder (e.g. WebP) are returned unchanged.
+func Thumbnail(img Image, width int) (Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return img, nil
+		}
+		return Image{}, err
+	}
+	if cfg.Width <= width {
+		return img, nil
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return Image{}, err
+	}
+	height := max(1, cfg.Height*width/cfg.Width)
+	dst := scale(src, width, height)
+
+	var out bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: jpegQuality})
+		return Image{ContentType: "image/jpeg", Data: out.Bytes()}, err
+	}
+	err = png.Encode(&out, dst)
+	return Image{ContentType: "image/png", Data: out.Bytes()}, err
+}
+
+// scale resizes src to width x height by averaging the source pixels each
+// destination pixel covers (box filter), which is sharp enough for
+// downscaling and avoids the aliasing of nearest neighbour.
+func scale(src image.Image, width int, height int) *image.NRGBA {
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*b.Dy()/height, max((y+1)*b.Dy()/height, y*b.Dy()/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*b.Dx()/width, max((x+1)*b.Dx()/width, x*b.Dx()/width+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, bl, a = r+uint64(p[0]), g+uint64(p[1]), bl+uint64(p[2]), a+uint64(p[3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			// RGBA 為預乘 alpha，轉回 NRGBA 時除以 alpha
+			if a > 0 {
+				dst.Pix[i] = uint8(r * 255 / a)
+				dst.Pix[i+1] = uint8(g * 255 / a)
+				dst.Pix[i+2] = uint8(bl * 255 / a)
+			}
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
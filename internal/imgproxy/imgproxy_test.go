@@ -0,0 +1,196 @@
+package imgproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func encodeImage(t *testing.T, format string, width int, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetch(t *testing.T) {
+	pngData := encodeImage(t, "png", 4, 4)
+	tests := []struct {
+		name     string
+		status   int
+		body     []byte
+		maxBytes int64
+		wantType string
+		wantErr  error
+	}{
+		{"png", http.StatusOK, pngData, 1 << 20, "image/png", nil},
+		{"too large", http.StatusOK, pngData, 10, "", ErrTooLarge},
+		{"html", http.StatusOK, []byte("<html><body>removed</body></html>"), 1 << 20, "", ErrNotImage},
+		{"svg", http.StatusOK, []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), 1 << 20, "", ErrNotImage},
+		{"not found", http.StatusNotFound, nil, 1 << 20, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Referer") != "" {
+					t.Error("Referer must not be sent")
+				}
+				// 上游宣稱的類型不可信
+				w.Header().Set("Content-Type", "image/png")
+				w.WriteHeader(tt.status)
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+
+			img, err := Fetch(context.Background(), srv.Client(), srv.URL+"/a.png", tt.maxBytes, "test")
+			switch {
+			case tt.status != http.StatusOK:
+				if err == nil {
+					t.Fatal("expected error for non-200 response")
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case img.ContentType != tt.wantType || !bytes.Equal(img.Data, tt.body):
+				t.Errorf("got %s (%d bytes), want %s (%d bytes)", img.ContentType, len(img.Data), tt.wantType, len(tt.body))
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name                string
+		format              string
+		width, height       int
+		thumbWidth          int
+		wantType            string
+		wantWidth, wantHigh int
+	}{
+		{"jpeg", "jpeg", 400, 300, 100, "image/jpeg", 100, 75},
+		{"png", "png", 200, 50, 64, "image/png", 64, 16},
+		{"gif becomes png", "gif", 120, 120, 30, "image/png", 30, 30},
+		{"already narrow", "png", 50, 80, 100, "image/png", 50, 80},
+		{"thin", "png", 1000, 1, 100, "image/png", 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeImage(t, tt.format, tt.width, tt.height)
+			thumb, err := Thumbnail(Image{ContentType: "image/" + tt.format, Data: data}, tt.thumbWidth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if thumb.ContentType != tt.wantType {
+				t.Errorf("content type = %s, want %s", thumb.ContentType, tt.wantType)
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(thumb.Data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.wantWidth || cfg.Height != tt.wantHigh {
+				t.Errorf("size = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.wantWidth, tt.wantHigh)
+			}
+		})
+	}
+}
+
+func TestThumbnailUnknownFormat(t *testing.T) {
+	// WebP 沒有標準函式庫解碼器，原樣回傳
+	webp := Image{ContentType: "image/webp", Data: []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")}
+	got, err := Thumbnail(webp, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data, webp.Data) || got.ContentType != webp.ContentType {
+		t.Error("unknown format should be returned unchanged")
+	}
+}
+
+func TestThumbnailRejectsPixelBomb(t *testing.T) {
+	// 只有標頭的 PNG 宣告 50000x50000 像素，解碼前就要拒絕
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	data := buf.Bytes()
+	// IHDR 的寬高位於第 16~23 byte，其後為 chunk 的 CRC
+	copy(data[16:24], []byte{0, 0, 0xc3, 0x50, 0, 0, 0xc3, 0x50})
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	if _, err := Thumbnail(Image{ContentType: "image/png", Data: data}, 100); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestScaleAveragesPixels(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	src.Set(1, 0, color.NRGBA{B: 255, A: 255})
+	got := scale(src, 1, 1).NRGBAAt(0, 0)
+	if got.R != 127 || got.B != 127 || got.A != 255 {
+		t.Errorf("scaled pixel = %+v, want the average of red and blue", got)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"1.1.1.1", true},
+		{"2606:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"192.168.0.10", false},
+		{"169.254.169.254", false}, // 雲端 metadata server
+		{"::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.0.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestPublicDialerRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{DialContext: PublicDialer().DialContext}}
+	if _, err := Fetch(context.Background(), client, srv.URL+"/a.png", 1<<20, "test"); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("err = %v, want ErrPrivateAddress", err)
+	}
+}
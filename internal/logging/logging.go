@@ -0,0 +1,180 @@
+// Package logging sets up the structured logger and carries a per-request
+// logger, tagged with the request ID, through the request context so every
+// upstream fetch made for one feed request can be found with one grep.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader is read from inbound requests and echoed on responses.
+const RequestIDHeader = "X-Request-ID"
+
+// New returns a logger writing to w. level is debug, info, warn or error;
+// format is "text" or "json". Invalid values fall back to info and text,
+// config.Validate rejects them before they get here.
+func New(w io.Writer, level string, format string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+type ctxKey struct{}
+
+// FromContext returns the request logger stored in ctx, or the default
+// logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromRequest returns the logger of r, or the default logger when r is nil.
+func FromRequest(r *http.Request) *slog.Logger {
+	if r == nil {
+		return slog.Default()
+	}
+	return FromContext(r.Context())
+}
+
+// Begin assigns r a request ID (reusing a sane X-Request-ID from the client),
+// echoes it on w and returns r with a logger carrying the ID, plus the trace
+// ID when r is already traced.
+func Begin(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	logger := slog.Default().With("request_id", id)
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return r.WithContext(WithLogger(r.Context(), logger))
+}
+
+// Middleware wraps h with Begin and logs one line per request.
+func Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = Begin(w, r)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+		LogRequest(r, sw.status, time.Since(start))
+	})
+}
+
+// LogRequest writes the access log line of r.
+func LogRequest(r *http.Request, status int, elapsed time.Duration) {
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	FromRequest(r).Log(r.Context(), level, "request",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"query", RedactedQuery(r.URL),
+		"status", status,
+		"duration", elapsed,
+	)
+}
+
+// Transport wraps an http.RoundTripper and logs every request at debug
+// level with logger. A nil next means http.DefaultTransport.
+func Transport(logger *slog.Logger, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			logger.Warn("upstream request failed", "method", req.Method, "url", req.URL.String(), "duration", time.Since(start), "err", err)
+			return nil, err
+		}
+		logger.Debug("upstream request", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "duration", time.Since(start))
+		return resp, nil
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// SensitiveParams are query parameters whose values never reach access logs
+// or traces, e.g. the API key feed readers pass as ?key= and the signature
+// of a signed feed URL.
+var SensitiveParams = []string{"key", "sig"}
+
+// RedactedQuery returns the raw query of u with the values of
+// SensitiveParams replaced by REDACTED, keeping the parameter order.
+func RedactedQuery(u *url.URL) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	pairs := strings.Split(u.RawQuery, "&")
+	for i, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		for _, sensitive := range SensitiveParams {
+			if name == sensitive {
+				pairs[i] = sensitive + "=REDACTED"
+			}
+		}
+	}
+	return strings.Join(pairs, "&")
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDReachesUpstreamLogs(t *testing.T) {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(New(&buf, "debug", "json"))
+	defer slog.SetDefault(original)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := &http.Client{Transport: Transport(FromRequest(r), nil)}
+		resp, err := client.Get(upstream.URL + "/bbs/C_Chat/index.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"client id", "abc-123", "abc-123"},
+		{"generated id", "", ""},
+		{"invalid id replaced", "bad id\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/ptt/search?board=C_Chat", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			h.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			switch {
+			case id == "":
+				t.Fatalf("response has no %s", RequestIDHeader)
+			case tt.want != "" && id != tt.want:
+				t.Fatalf("request ID = %q, want %q", id, tt.want)
+			case tt.want == "" && id == tt.header:
+				t.Fatalf("request ID %q was not replaced", id)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got %d log lines, want upstream + request:\n%s", len(lines), buf.String())
+			}
+			for i, want := range []string{"upstream request", "request"} {
+				var entry map[string]any
+				if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+					t.Fatal(err)
+				}
+				if entry["msg"] != want || entry["request_id"] != id {
+					t.Errorf("line %d = %s, want msg %q with request_id %q", i, lines[i], want, id)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactedQuery(t *testing.T) {
+	tests := []struct {
+		rawQuery string
+		expected string
+	}{
+		{"", ""},
+		{"board=C_Chat&mode=all", "board=C_Chat&mode=all"},
+		{"board=C_Chat&key=secret-key-0123456789&format=json", "board=C_Chat&key=REDACTED&format=json"},
+		{"%6Bey=secret", "key=REDACTED"},
+		{"keyword=key", "keyword=key"},
+		{"board=C_Chat&exp=1769097600&sig=c2lnbmF0dXJl", "board=C_Chat&exp=1769097600&sig=REDACTED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rawQuery, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ptt/trending?"+tt.rawQuery, nil)
+			if got := RedactedQuery(r.URL); got != tt.expected {
+				t.Errorf("RedactedQuery() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
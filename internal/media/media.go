@@ -0,0 +1,119 @@
+// Package media finds the images linked from feed item HTML. Images are
+// emitted as RSS enclosures, Media RSS media:content and JSON Feed image so
+// readers can show them without opening the article, and their count feeds
+// the viral prediction.
+package media
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// imgurID matches imgur image IDs; albums (/a/) and galleries (/gallery/)
+// have no single direct image and are skipped.
+var imgurID = regexp.MustCompile(`^[A-Za-z0-9]{5,8}$`)
+
+var imageTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// Images returns the direct image URLs of the images and links in fragment,
+// in order and without duplicates. Only imgur, Twitter (pbs.twimg.com) and
+// Plurk (images.plurk.com) images are recognized; imgur page links become
+// i.imgur.com URLs and Plurk thumbnails their full-size image.
+func Images(fragment string) []string {
+	var images []string
+	seen := make(map[string]bool)
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return images
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		var key string
+		switch tok.DataAtom {
+		case atom.Img:
+			key = "src"
+		case atom.A:
+			key = "href"
+		default:
+			continue
+		}
+		for _, a := range tok.Attr {
+			if a.Key != key {
+				continue
+			}
+			if image, ok := Normalize(a.Val); ok && !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+}
+
+// Normalize returns the direct image URL of raw, or false when raw is not a
+// recognized image link.
+func Normalize(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "") {
+		return "", false
+	}
+	u.Scheme = "https"
+	u.Fragment = ""
+	ext := strings.ToLower(path.Ext(u.Path))
+
+	switch strings.ToLower(u.Host) {
+	case "imgur.com", "www.imgur.com", "m.imgur.com", "i.imgur.com":
+		name := strings.TrimPrefix(u.Path, "/")
+		id := strings.TrimSuffix(name, path.Ext(name))
+		if !imgurID.MatchString(id) {
+			return "", false
+		}
+		if _, ok := imageTypes[ext]; !ok {
+			ext = ".jpg" // imgur 依檔名回傳實際格式，頁面連結沒有副檔名
+		}
+		return "https://i.imgur.com/" + id + ext, true
+	case "pbs.twimg.com":
+		if !strings.HasPrefix(u.Path, "/media/") {
+			return "", false
+		}
+		return u.String(), true
+	case "images.plurk.com":
+		if _, ok := imageTypes[ext]; !ok {
+			return "", false
+		}
+		dir, file := path.Split(u.Path)
+		u.Path = dir + strings.TrimPrefix(file, "mx_")
+		return u.String(), true
+	}
+	return "", false
+}
+
+// Type returns the MIME type of an image URL returned by Images.
+func Type(image string) string {
+	u, err := url.Parse(image)
+	if err != nil {
+		return "image/jpeg"
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if format := u.Query().Get("format"); format != "" {
+		ext = "." + format // pbs.twimg.com/media/ID?format=png&name=large
+	}
+	if t, ok := imageTypes[ext]; ok {
+		return t
+	}
+	return "image/jpeg"
+}
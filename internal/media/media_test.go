@@ -0,0 +1,67 @@
+package media
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+	}{
+		{"https://i.imgur.com/AbCd123.jpg", "https://i.imgur.com/AbCd123.jpg"},
+		{"http://i.imgur.com/AbCd123.png", "https://i.imgur.com/AbCd123.png"},
+		{"https://imgur.com/XyZ9876", "https://i.imgur.com/XyZ9876.jpg"},
+		{"//m.imgur.com/XyZ9876", "https://i.imgur.com/XyZ9876.jpg"},
+		{"https://imgur.com/a/XyZ9876", ""},
+		{"https://imgur.com/gallery/XyZ9876", ""},
+		{"https://pbs.twimg.com/media/GabcDEF?format=jpg&name=large", "https://pbs.twimg.com/media/GabcDEF?format=jpg&name=large"},
+		{"https://pbs.twimg.com/profile_images/1/a.jpg", ""},
+		{"https://images.plurk.com/mx_5Nq2bGxYfZkZ.jpg", "https://images.plurk.com/5Nq2bGxYfZkZ.jpg"},
+		{"https://s.plurk.com/emoticons/platinum/cold.gif", ""},
+		{"https://example.com/a.jpg", ""},
+		{"javascript:alert(1)", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, ok := Normalize(tt.raw)
+			if got != tt.expected || ok != (tt.expected != "") {
+				t.Errorf("Normalize() = %q, %v; want %q", got, ok, tt.expected)
+			}
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	fragment := `看圖<br><a href="https://i.imgur.com/AbCd123.jpg">https://i.imgur.com/AbCd123.jpg</a>
+<a href="https://imgur.com/AbCd123">同一張</a><a href="https://imgur.com/XyZ9876">https://imgur.com/XyZ9876</a>
+<a href="https://images.plurk.com/5Nq2bGxYfZkZ.jpg"><img src="https://images.plurk.com/mx_5Nq2bGxYfZkZ.jpg"></a>
+<img src="https://s.plurk.com/emoticons/platinum/cold.gif"><a href="https://www.ptt.cc/bbs/C_Chat/index.html">看板</a>`
+
+	expected := []string{
+		"https://i.imgur.com/AbCd123.jpg",
+		"https://i.imgur.com/XyZ9876.jpg",
+		"https://images.plurk.com/5Nq2bGxYfZkZ.jpg",
+	}
+	if got := Images(fragment); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Images() = %q, want %q", got, expected)
+	}
+	if got := Images("純文字"); got != nil {
+		t.Errorf("Images() = %q, want none", got)
+	}
+}
+
+func TestType(t *testing.T) {
+	tests := map[string]string{
+		"https://i.imgur.com/AbCd123.png":                 "image/png",
+		"https://i.imgur.com/AbCd123.jpg":                 "image/jpeg",
+		"https://pbs.twimg.com/media/GabcDEF?format=webp": "image/webp",
+		"https://pbs.twimg.com/media/GabcDEF":             "image/jpeg",
+	}
+	for image, expected := range tests {
+		if got := Type(image); got != expected {
+			t.Errorf("Type(%q) = %q, want %q", image, got, expected)
+		}
+	}
+}
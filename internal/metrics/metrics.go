@@ -0,0 +1,156 @@
+// Package metrics defines the Prometheus collectors exposed on /metrics and
+// small helpers for recording them from the server and the handlers.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// HTTPRequests counts inbound requests by route pattern, method and status.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_tool_http_requests_total",
+		Help: "Inbound HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration observes inbound request latency by route pattern.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "feed_tool_http_request_duration_seconds",
+		Help:    "Inbound HTTP request latency by route.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"route"})
+
+	// UpstreamRequests counts requests to ptt.cc and plurk.com by host and
+	// status code; transport failures use status "error".
+	UpstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_tool_upstream_requests_total",
+		Help: "Upstream HTTP requests by host and status code.",
+	}, []string{"host", "status"})
+
+	// UpstreamDuration observes upstream request latency by host.
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "feed_tool_upstream_request_duration_seconds",
+		Help:    "Upstream HTTP request latency by host.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"host"})
+
+	// ParseFailures counts upstream items dropped because they could not be
+	// parsed, e.g. a PTT article skipped from a search feed.
+	ParseFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_tool_parse_failures_total",
+		Help: "Upstream items skipped because parsing failed, by source.",
+	}, []string{"source"})
+
+	// Predictions counts prediction service calls by result ("ok" or "error").
+	Predictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_tool_predictions_total",
+		Help: "Prediction service calls by result.",
+	}, []string{"result"})
+
+	// PredictionDuration observes prediction service latency.
+	PredictionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "feed_tool_prediction_duration_seconds",
+		Help:    "Prediction service call latency.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// CacheLookups counts cache lookups by cache and result ("hit" or
+	// "miss"); the hit ratio is hit / (hit + miss).
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_tool_cache_lookups_total",
+		Help: "Cache lookups by cache name and result.",
+	}, []string{"cache", "result"})
+
+	// FeedItems observes the number of items in each generated feed.
+	FeedItems = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "feed_tool_feed_items",
+		Help:    "Items per generated feed by route.",
+		Buckets: []float64{0, 1, 5, 10, 20, 50, 100},
+	}, []string{"route"})
+
+	// APIKeyRequests counts authenticated requests by key name and result
+	// ("ok", "rate_limited", "quota_exceeded"); rejected keys use name "-"
+	// with result "missing" or "invalid".
+	APIKeyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_tool_api_key_requests_total",
+		Help: "Requests checked against the API key store by key name and result.",
+	}, []string{"key", "result"})
+
+	// RateLimited counts feed requests rejected by the per-IP rate limit.
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_tool_rate_limited_total",
+		Help: "Feed requests rejected by the per-client rate limit by route.",
+	}, []string{"route"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRequest records one inbound request.
+func ObserveRequest(route string, method string, status int, elapsed time.Duration) {
+	HTTPRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	HTTPDuration.WithLabelValues(route).Observe(elapsed.Seconds())
+}
+
+// ObserveCache records a cache lookup.
+func ObserveCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// ObservePrediction records one prediction service call.
+func ObservePrediction(elapsed time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	Predictions.WithLabelValues(result).Inc()
+	PredictionDuration.Observe(elapsed.Seconds())
+}
+
+// Transport wraps an http.RoundTripper and counts every request by host and
+// status. A nil next means http.DefaultTransport.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return TransportAs("", next)
+}
+
+// TransportAs is Transport with every request labelled host instead of its
+// own host, for clients that reach arbitrary hosts, e.g. the image proxy,
+// so the label values stay bounded.
+func TransportAs(host string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		label := host
+		if label == "" {
+			label = req.URL.Host
+		}
+		UpstreamDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+		if err != nil {
+			UpstreamRequests.WithLabelValues(label, "error").Inc()
+			return nil, err
+		}
+		UpstreamRequests.WithLabelValues(label, strconv.Itoa(resp.StatusCode)).Inc()
+		return resp, nil
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTransportCountsByHostAndStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	client := &http.Client{Transport: Transport(nil)}
+	for _, path := range []string{"/a", "/b", "/missing"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if got := testutil.ToFloat64(UpstreamRequests.WithLabelValues(host, "200")); got != 2 {
+		t.Errorf("200 count = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(UpstreamRequests.WithLabelValues(host, "404")); got != 1 {
+		t.Errorf("404 count = %v, want 1", got)
+	}
+
+	failing := &http.Client{Transport: Transport(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("dial failed")
+	}))}
+	if _, err := failing.Get("http://unreachable.test/"); err == nil {
+		t.Fatal("expected transport error")
+	}
+	if got := testutil.ToFloat64(UpstreamRequests.WithLabelValues("unreachable.test", "error")); got != 1 {
+		t.Errorf("error count = %v, want 1", got)
+	}
+}
+
+func TestTransportAsUsesFixedHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: TransportAs("test_proxy", nil)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := testutil.ToFloat64(UpstreamRequests.WithLabelValues("test_proxy", "200")); got != 1 {
+		t.Errorf("test_proxy count = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(UpstreamRequests.WithLabelValues(strings.TrimPrefix(server.URL, "http://"), "200")); got != 0 {
+		t.Errorf("per-host count = %v, want 0", got)
+	}
+}
+
+func TestObserveCache(t *testing.T) {
+	ObserveCache("test", true)
+	ObserveCache("test", true)
+	ObserveCache("test", false)
+
+	if got := testutil.ToFloat64(CacheLookups.WithLabelValues("test", "hit")); got != 2 {
+		t.Errorf("hits = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(CacheLookups.WithLabelValues("test", "miss")); got != 1 {
+		t.Errorf("misses = %v, want 1", got)
+	}
+}
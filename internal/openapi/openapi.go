@@ -0,0 +1,349 @@
+// Package openapi builds the OpenAPI 3 document of the feed tool from the
+// route table, and serves it together with a Swagger UI docs page.
+package openapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/auth"
+	"github.com/Harrison-Dev/go_feed_tool/internal/handler"
+)
+
+// Version is the API version reported in the spec.
+const Version = "1.0.0"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	Default    any                `json:"default,omitempty"`
+	Minimum    *float64           `json:"minimum,omitempty"`
+	Maximum    *float64           `json:"maximum,omitempty"`
+	Pattern    string             `json:"pattern,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Spec returns the OpenAPI document describing routes.
+func Spec(routes []handler.Route) Document {
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "go_feed_tool",
+			Description: "PTT / Plurk 內容轉 RSS、Atom 與 JSON Feed，並以 AI 預測 PTT 潛在爆文。",
+			Version:     Version,
+		},
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: components(), SecuritySchemes: securitySchemes()},
+	}
+	for _, rt := range routes {
+		item := doc.Paths[rt.Path]
+		switch rt.HTTPMethod() {
+		case http.MethodPost:
+			item.Post = operation(rt)
+		case http.MethodPut:
+			item.Put = operation(rt)
+		case http.MethodDelete:
+			item.Delete = operation(rt)
+		default:
+			item.Get = operation(rt)
+		}
+		doc.Paths[rt.Path] = item
+	}
+	return doc
+}
+
+func operation(rt handler.Route) *Operation {
+	op := &Operation{
+		OperationID: rt.Function,
+		Summary:     rt.Summary,
+		Description: rt.Description,
+		Responses:   make(map[string]Response),
+	}
+	for _, p := range rt.AllParams() {
+		in := p.In
+		if in == "" {
+			in = "query"
+		}
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        p.Name,
+			In:          in,
+			Description: p.Description,
+			Required:    p.Required,
+			Schema:      paramSchema(p),
+		})
+	}
+
+	switch {
+	case rt.Feed:
+		op.Responses["200"] = Response{
+			Description: "Feed (依 format 參數)",
+			Content: map[string]MediaType{
+				"application/rss+xml":  {Schema: &Schema{Type: "string"}},
+				"application/atom+xml": {Schema: &Schema{Type: "string"}},
+				"application/feed+json": {Schema: &Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{"version": {Type: "string"}, "items": {Type: "array", Items: &Schema{Type: "object"}}},
+					Required:   []string{"version", "items"},
+				}},
+			},
+		}
+		op.Responses["500"] = Response{
+			Description: "上游 (PTT / Plurk) 請求或解析失敗",
+			Content:     map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}},
+		}
+		// 只有設定 auth.keys_file 時才需要 key；兩種帶法或簽章網址擇一
+		op.Security = []map[string][]string{{"apiKeyHeader": {}}, {"apiKeyQuery": {}}, {"signedURL": {}}}
+		op.Responses["401"] = Response{Description: "缺少或無效的 API key", Content: apiError()}
+		op.Responses["403"] = Response{Description: "簽章無效或已過期", Content: apiError()}
+		op.Responses["429"] = Response{Description: "來源 IP 請求過於頻繁，或超過 API key 的速率限制或每日額度，見 Retry-After", Content: apiError()}
+	case rt.Admin:
+		op.Security = []map[string][]string{{"adminToken": {}}}
+		object := map[string]MediaType{rt.ContentType: {Schema: &Schema{Type: "object"}}}
+		switch rt.HTTPMethod() {
+		case http.MethodDelete:
+			op.Responses["204"] = Response{Description: "已刪除"}
+		case http.MethodPost, http.MethodPut:
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: &Schema{Type: "object"}}}}
+			op.Responses["201"] = Response{Description: "已新增", Content: object}
+			op.Responses["400"] = Response{
+				Description: "body 或定義錯誤",
+				Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/ValidationError"}}},
+			}
+		}
+		if rt.HTTPMethod() == http.MethodGet || rt.HTTPMethod() == http.MethodPut {
+			op.Responses["200"] = Response{Description: "OK", Content: object}
+		}
+		if rt.HTTPMethod() != http.MethodGet {
+			op.Responses["409"] = Response{Description: "ID 已存在、定義來自設定檔，或未設定 saved_feeds.file", Content: apiError()}
+		}
+		op.Responses["401"] = Response{Description: "缺少或無效的 admin token", Content: apiError()}
+		notFound := "未設定 auth.admin_token，管理 API 停用"
+		if strings.Contains(rt.Path, "{id}") {
+			notFound += "；或找不到此 ID"
+		}
+		op.Responses["404"] = Response{Description: notFound, Content: apiError()}
+	case rt.Signed:
+		op.Security = []map[string][]string{{"signedURL": {}}}
+		op.Responses["200"] = Response{
+			Description: "圖片 (JPEG、PNG、GIF 或 WebP)",
+			Content:     map[string]MediaType{rt.ContentType: {Schema: &Schema{Type: "string", Format: "binary"}}},
+		}
+		op.Responses["403"] = Response{Description: "簽章無效", Content: apiError()}
+		op.Responses["502"] = Response{Description: "上游圖片無法取得、過大或不是圖片", Content: apiError()}
+	case rt.Path == "/ready":
+		readiness := map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Readiness"}}}
+		op.Responses["200"] = Response{Description: "ready 或 degraded", Content: readiness}
+		op.Responses["503"] = Response{Description: "not_ready 或 shutting_down", Content: readiness}
+	default:
+		contentType := rt.ContentType
+		if contentType == "" {
+			contentType = "text/plain"
+		}
+		op.Responses["200"] = Response{
+			Description: "OK",
+			Content:     map[string]MediaType{contentType: {Schema: &Schema{Type: "string"}}},
+		}
+	}
+	if len(op.Parameters) > 0 {
+		op.Responses["400"] = Response{
+			Description: "參數錯誤",
+			Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/ValidationError"}}},
+		}
+	}
+	return op
+}
+
+func apiError() map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}
+}
+
+func paramSchema(p handler.Param) *Schema {
+	s := &Schema{Type: p.Type, Format: p.Format, Enum: p.Enum, Pattern: p.Pattern, Minimum: p.Min, Maximum: p.Max}
+	if p.Type == handler.ParamDuration {
+		// Go duration 以字串表示，例如 90m、2h
+		s.Type, s.Format, s.Minimum, s.Maximum = "string", "duration", nil, nil
+		s.Pattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	}
+	if p.Default != "" {
+		s.Default = p.Default
+		if p.Type == handler.ParamInteger || p.Type == handler.ParamNumber {
+			if n, err := strconv.ParseFloat(p.Default, 64); err == nil {
+				s.Default = n
+			}
+		}
+	}
+	return s
+}
+
+func components() map[string]*Schema {
+	return map[string]*Schema{
+		"ValidationError": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"error": {Type: "string"},
+				"errors": {Type: "array", Items: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"param":   {Type: "string"},
+						"value":   {Type: "string"},
+						"message": {Type: "string"},
+					},
+					Required: []string{"param", "message"},
+				}},
+			},
+			Required: []string{"error", "errors"},
+		},
+		"Error": {
+			Type:       "object",
+			Properties: map[string]*Schema{"error": {Type: "string"}},
+			Required:   []string{"error"},
+		},
+		"Readiness": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"status": {Type: "string", Enum: []string{"ready", "degraded", "not_ready", "shutting_down"}},
+				"checks": {Type: "object"},
+			},
+			Required: []string{"status", "checks"},
+		},
+	}
+}
+
+func securitySchemes() map[string]SecurityScheme {
+	const description = "設定 auth.keys_file 時 feed 路由需要 API key"
+	return map[string]SecurityScheme{
+		"apiKeyHeader": {Type: "apiKey", Name: auth.Header, In: "header", Description: description},
+		"apiKeyQuery":  {Type: "apiKey", Name: "key", In: "query", Description: description + " (無法設定 header 的 feed 閱讀器使用)"},
+		"signedURL":    {Type: "apiKey", Name: "sig", In: "query", Description: "由 /admin/sign 或 cmd/signurl 產生的簽章網址，不需要 API key"},
+		"adminToken":   {Type: "http", Scheme: "bearer", Description: "auth.admin_token"},
+	}
+}
+
+// Routes returns the /openapi.json route describing routes and the /docs
+// page rendering it.
+func Routes(routes []handler.Route) []handler.Route {
+	return []handler.Route{
+		{Path: "/openapi.json", Summary: "OpenAPI 規格", ContentType: "application/json", Handler: specHandler(routes)},
+		{Path: "/docs", Summary: "API 文件 (Swagger UI)", ContentType: "text/html", Handler: docsHandler("/openapi.json")},
+	}
+}
+
+func specHandler(routes []handler.Route) http.HandlerFunc {
+	body, err := json.MarshalIndent(Spec(routes), "", "  ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(body)
+	}
+}
+
+// swaggerUI is the exact Swagger UI release the docs page loads. The CSP of
+// the page only allows scripts and styles under this path, so a floating
+// version or another package on the CDN cannot run on our origin.
+const swaggerUI = "https://unpkg.com/swagger-ui-dist@5.17.14/"
+
+func docsHandler(specURL string) http.HandlerFunc {
+	quoted, _ := json.Marshal(specURL)
+	script := "window.ui = SwaggerUIBundle({ url: " + string(quoted) + `, dom_id: "#swagger-ui", validatorUrl: null });`
+	page := strings.NewReplacer("{{SWAGGER_UI}}", swaggerUI, "{{INIT}}", script).Replace(docsPage)
+	sum := sha256.Sum256([]byte(script))
+	csp := fmt.Sprintf("default-src 'none'; script-src %s 'sha256-%s'; style-src %s; img-src 'self' data:; connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'",
+		swaggerUI, base64.StdEncoding.EncodeToString(sum[:]), swaggerUI)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", csp)
+		w.Write([]byte(page))
+	}
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="zh-Hant">
+<head>
+  <meta charset="utf-8">
+  <title>go_feed_tool API</title>
+  <link rel="stylesheet" href="{{SWAGGER_UI}}swagger-ui.css" crossorigin="anonymous" referrerpolicy="no-referrer">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{SWAGGER_UI}}swagger-ui-bundle.js" crossorigin="anonymous" referrerpolicy="no-referrer"></script>
+  <script>{{INIT}}</script>
+</body>
+</html>
+`
@@ -0,0 +1,119 @@
+package openapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/handler"
+)
+
+func TestSpecCoversRoutes(t *testing.T) {
+	doc := Spec(handler.Routes)
+
+	for _, route := range handler.Routes {
+		item, ok := doc.Paths[route.Path]
+		if !ok || item.Get == nil {
+			t.Errorf("spec is missing GET %s", route.Path)
+			continue
+		}
+		if item.Get.OperationID != route.Function {
+			t.Errorf("%s operationId = %q, want %q", route.Path, item.Get.OperationID, route.Function)
+		}
+		if len(item.Get.Parameters) != len(route.AllParams()) {
+			t.Errorf("%s has %d parameters, want %d", route.Path, len(item.Get.Parameters), len(route.AllParams()))
+		}
+		if _, ok := item.Get.Responses["400"]; !ok {
+			t.Errorf("%s does not document its 400 response", route.Path)
+		}
+	}
+
+	params := make(map[string]Parameter)
+	for _, p := range doc.Paths["/ptt/trending"].Get.Parameters {
+		params[p.Name] = p
+	}
+	tests := []struct {
+		name     string
+		typ      string
+		format   string
+		minimum  float64
+		enumSize int
+	}{
+		{name: "limit", typ: "integer", minimum: 1},
+		{name: "threshold", typ: "number"},
+		{name: "max_age", typ: "string", format: "duration"},
+		{name: "mode", typ: "string", enumSize: 4},
+		{name: "format", typ: "string", enumSize: 3},
+	}
+	for _, tt := range tests {
+		p, ok := params[tt.name]
+		if !ok {
+			t.Errorf("/ptt/trending is missing parameter %s", tt.name)
+			continue
+		}
+		if p.Schema.Type != tt.typ || p.Schema.Format != tt.format || len(p.Schema.Enum) != tt.enumSize {
+			t.Errorf("%s schema = %+v", tt.name, p.Schema)
+		}
+		if tt.minimum != 0 && (p.Schema.Minimum == nil || *p.Schema.Minimum != tt.minimum) {
+			t.Errorf("%s minimum = %v, want %v", tt.name, p.Schema.Minimum, tt.minimum)
+		}
+	}
+}
+
+func TestSpecRoute(t *testing.T) {
+	routes := Routes(handler.Routes)
+	w := httptest.NewRecorder()
+	routes[0].Handler(w, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("openapi = %v, want 3.0.3", doc["openapi"])
+	}
+	ref := doc["paths"].(map[string]any)["/ptt/search"].(map[string]any)["get"].(map[string]any)["responses"].(map[string]any)["400"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)["$ref"]
+	if ref != "#/components/schemas/ValidationError" {
+		t.Errorf("400 schema = %v", ref)
+	}
+}
+
+func TestSpecAdminMethods(t *testing.T) {
+	doc := Spec(handler.AdminRoutes)
+
+	item := doc.Paths["/admin/feeds/{id}"]
+	if item.Get == nil || item.Put == nil || item.Delete == nil || item.Post != nil {
+		t.Fatalf("/admin/feeds/{id} operations = %+v", item)
+	}
+	if p := item.Put.Parameters; len(p) != 1 || p[0].In != "path" || !p[0].Required {
+		t.Errorf("PUT parameters = %+v, want the required id path parameter", p)
+	}
+	if item.Put.RequestBody == nil || item.Delete.Responses["204"].Description == "" {
+		t.Errorf("PUT request body = %v, DELETE responses = %v", item.Put.RequestBody, item.Delete.Responses)
+	}
+	if post := doc.Paths["/admin/feeds"].Post; post == nil || post.Responses["201"].Description == "" {
+		t.Errorf("POST /admin/feeds = %+v", post)
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	routes := Routes(handler.Routes)
+	w := httptest.NewRecorder()
+	routes[1].Handler(w, httptest.NewRequest("GET", "/docs", nil))
+
+	body := w.Body.String()
+	if strings.Contains(body, "swagger-ui-dist@5/") || !strings.Contains(body, swaggerUI+"swagger-ui-bundle.js") {
+		t.Errorf("docs page does not pin Swagger UI:\n%s", body)
+	}
+	// 頁面內的初始化 script 需符合 CSP 中的雜湊
+	start := strings.Index(body, "<script>") + len("<script>")
+	script := body[start : start+strings.Index(body[start:], "</script>")]
+	sum := sha256.Sum256([]byte(script))
+	csp := w.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "script-src "+swaggerUI+" 'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'") {
+		t.Errorf("CSP %q does not allow the init script %q", csp, script)
+	}
+}
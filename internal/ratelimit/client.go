@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter keeps one bucket per client. It is safe for concurrent use.
+type Limiter struct {
+	perMinute int
+	burst     int
+
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	swept   time.Time
+}
+
+// NewLimiter returns a limiter giving every client perMinute tokens per
+// minute and at most burst tokens.
+func NewLimiter(perMinute int, burst int) *Limiter {
+	return &Limiter{perMinute: perMinute, burst: burst, buckets: make(map[string]*Bucket)}
+}
+
+// Take charges cost tokens to client at now, see Bucket.Take.
+func (l *Limiter) Take(client string, now time.Time, cost float64) (bool, time.Duration) {
+	return l.bucket(client, now).Take(now, cost)
+}
+
+// Charge charges cost tokens to client at now, see Bucket.Charge.
+func (l *Limiter) Charge(client string, now time.Time, cost float64) {
+	l.bucket(client, now).Charge(now, cost)
+}
+
+func (l *Limiter) bucket(client string, now time.Time) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// 已補滿的 bucket 等同新的 bucket，定期清掉以免 map 無限成長
+	if now.Sub(l.swept) >= time.Minute {
+		for c, b := range l.buckets {
+			if b.Remaining(now) >= l.burst {
+				delete(l.buckets, c)
+			}
+		}
+		l.swept = now
+	}
+	b, ok := l.buckets[client]
+	if !ok {
+		b = NewBucket(l.perMinute, l.burst)
+		l.buckets[client] = b
+	}
+	return b
+}
+
+// Clients returns the number of clients currently tracked.
+func (l *Limiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// Proxies are the reverse proxies (e.g. Traefik) whose X-Forwarded-For
+// header is trusted.
+type Proxies []netip.Prefix
+
+// ParseProxies parses CIDR ranges or single addresses.
+func ParseProxies(values []string) (Proxies, error) {
+	var proxies Proxies
+	for _, v := range values {
+		if prefix, err := netip.ParsePrefix(v); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", v)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return proxies, nil
+}
+
+func (p Proxies) trusted(addr netip.Addr) bool {
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that sent r. When the
+// connection comes from a trusted proxy, X-Forwarded-For is read from the
+// right, skipping trusted proxies; the first other address is the client.
+// Addresses left of it were supplied by the client and cannot be trusted.
+func (p Proxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	remote = remote.Unmap()
+	if !p.trusted(remote) {
+		return remote.String()
+	}
+
+	client := remote
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !p.trusted(client) {
+			break
+		}
+	}
+	return client.String()
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseProxies([]string{"172.16.0.0/12", "10.0.0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		expected   string
+	}{
+		{"direct client", "203.0.113.7:51000", nil, "203.0.113.7"},
+		{"untrusted peer cannot spoof", "203.0.113.7:51000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"behind traefik", "172.18.0.2:40000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"client-supplied prefix ignored", "172.18.0.2:40000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"chained trusted proxies", "172.18.0.2:40000", []string{"198.51.100.1, 10.0.0.5"}, "198.51.100.1"},
+		{"multiple headers", "172.18.0.2:40000", []string{"1.2.3.4", "198.51.100.1"}, "198.51.100.1"},
+		{"garbage stops the walk", "172.18.0.2:40000", []string{"198.51.100.1, not-an-ip"}, "172.18.0.2"},
+		{"no header", "172.18.0.2:40000", nil, "172.18.0.2"},
+		{"ipv6", "[2001:db8::1]:443", nil, "2001:db8::1"},
+		{"ipv4-mapped proxy", "[::ffff:172.18.0.2]:40000", []string{"198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ptt/search", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := proxies.ClientIP(r); got != tt.expected {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ParseProxies([]string{"traefik"}); err == nil {
+		t.Error("ParseProxies accepted a host name")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	start := time.Date(2026, 1, 22, 20, 0, 0, 0, time.UTC)
+	l := NewLimiter(60, 5)
+
+	if ok, _ := l.Take("198.51.100.1", start, 5); !ok {
+		t.Fatal("first request rejected")
+	}
+	ok, wait := l.Take("198.51.100.1", start, 5)
+	if ok || wait != 5*time.Second {
+		t.Errorf("second request = %v, %v; want rejected for 5s", ok, wait)
+	}
+	if ok, _ := l.Take("198.51.100.2", start, 5); !ok {
+		t.Error("another client shares the first client's bucket")
+	}
+
+	// 補滿的 bucket 會在下次清理時移除
+	if ok, _ := l.Take("198.51.100.3", start.Add(time.Minute), 1); !ok {
+		t.Error("third client rejected")
+	}
+	if n := l.Clients(); n != 1 {
+		t.Errorf("Clients() = %d after sweep, want 1", n)
+	}
+}
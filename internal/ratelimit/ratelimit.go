@@ -0,0 +1,79 @@
+// Package ratelimit implements the token buckets used to limit API keys and
+// inbound clients.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Never is the wait Take returns when cost tokens will never be available:
+// the cost exceeds the burst size or the bucket does not refill. It is not a
+// real duration; callers must answer such requests without Retry-After.
+const Never = time.Duration(math.MaxInt64)
+
+// Bucket is a token bucket refilled at a constant rate up to its burst size.
+// It is safe for concurrent use.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a full bucket that refills perMinute tokens per minute
+// and holds at most burst tokens.
+func NewBucket(perMinute int, burst int) *Bucket {
+	return &Bucket{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Take removes cost tokens at now. When the bucket holds fewer than cost
+// tokens nothing is taken, and Take returns false with the time until
+// enough tokens will have been refilled, or Never.
+func (b *Bucket) Take(now time.Time, cost float64) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if b.tokens >= cost {
+		b.tokens -= cost
+		return true, 0
+	}
+	if b.rate <= 0 || cost > b.burst {
+		return false, Never
+	}
+	wait := time.Duration(math.Ceil((cost - b.tokens) / b.rate * float64(time.Second)))
+	return false, wait
+}
+
+// Charge removes cost tokens at now even if that leaves the bucket in debt,
+// for work already done; later Takes wait until the debt is refilled.
+func (b *Bucket) Charge(now time.Time, cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	b.tokens -= cost
+}
+
+// Remaining returns the whole tokens left at now.
+func (b *Bucket) Remaining(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	return int(b.tokens)
+}
+
+func (b *Bucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+}
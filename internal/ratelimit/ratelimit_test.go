@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	start := time.Date(2026, 1, 22, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		perMinute int
+		burst     int
+		takes     []time.Duration // offsets from start, one token each
+		wantOK    []bool
+		wantWait  time.Duration // wait returned by the last take
+	}{
+		{
+			name:      "burst then refill",
+			perMinute: 60,
+			burst:     2,
+			takes:     []time.Duration{0, 0, 0, time.Second},
+			wantOK:    []bool{true, true, false, true},
+		},
+		{
+			name:      "wait until next token",
+			perMinute: 6,
+			burst:     1,
+			takes:     []time.Duration{0, 4 * time.Second},
+			wantOK:    []bool{true, false},
+			wantWait:  6 * time.Second,
+		},
+		{
+			name:      "refill capped at burst",
+			perMinute: 60,
+			burst:     1,
+			takes:     []time.Duration{time.Hour, time.Hour, time.Hour + time.Second},
+			wantOK:    []bool{true, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBucket(tt.perMinute, tt.burst)
+			var wait time.Duration
+			for i, offset := range tt.takes {
+				var ok bool
+				ok, wait = b.Take(start.Add(offset), 1)
+				if ok != tt.wantOK[i] {
+					t.Errorf("take %d at +%v = %v, want %v", i, offset, ok, tt.wantOK[i])
+				}
+			}
+			if wait != tt.wantWait {
+				t.Errorf("last wait = %v, want %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestBucketCostOverBurst(t *testing.T) {
+	b := NewBucket(60, 10)
+	if ok, wait := b.Take(time.Now(), 11); ok || wait != Never {
+		t.Errorf("Take(11) = %v, %v; want false, Never", ok, wait)
+	}
+	if got := b.Remaining(time.Now()); got != 10 {
+		t.Errorf("Remaining = %d, want the bucket untouched", got)
+	}
+}
+
+func TestBucketCharge(t *testing.T) {
+	start := time.Date(2026, 1, 22, 20, 0, 0, 0, time.UTC)
+	b := NewBucket(60, 10)
+	b.Charge(start, 15)
+	if got := b.Remaining(start); got != -5 {
+		t.Errorf("Remaining = %d, want -5 after charging past the burst", got)
+	}
+	if ok, wait := b.Take(start, 1); ok || wait != 6*time.Second {
+		t.Errorf("Take(1) = %v, %v; want false, 6s", ok, wait)
+	}
+}
@@ -0,0 +1,309 @@
+// Package sanitize cleans the HTML embedded in feed item descriptions. PTT
+// article bodies and Plurk posts are copied from upstream pages, so they
+// carry site styling, embed scripts and arbitrary markup that feed readers
+// should not render.
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowed lists the elements kept in the output and their allowed
+// attributes. Other elements are unwrapped: the tag is dropped, its content
+// kept.
+var allowed = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Br:         nil,
+	atom.P:          nil,
+	atom.Div:        nil,
+	atom.Span:       nil, // 只保留由 PTT 色碼轉換的 style
+	atom.B:          nil,
+	atom.Strong:     nil,
+	atom.I:          nil,
+	atom.Em:         nil,
+	atom.U:          nil,
+	atom.S:          nil,
+	atom.Del:        nil,
+	atom.Blockquote: nil,
+	atom.Pre:        nil,
+	atom.Code:       nil,
+	atom.Ul:         nil,
+	atom.Ol:         nil,
+	atom.Li:         nil,
+	atom.Hr:         nil,
+}
+
+// dropped elements are removed together with their content. Iframes are
+// replaced by a link to their source first.
+var dropped = map[atom.Atom]bool{
+	atom.Iframe:   true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Form:     true,
+	atom.Head:     true,
+	atom.Title:    true,
+}
+
+// voids have no closing tag.
+var voids = map[atom.Atom]bool{
+	atom.Area: true, atom.Base: true, atom.Br: true, atom.Col: true, atom.Embed: true, atom.Hr: true, atom.Img: true,
+	atom.Input: true, atom.Link: true, atom.Meta: true, atom.Source: true, atom.Track: true, atom.Wbr: true,
+}
+
+// openElement is an element whose end tag has not been seen yet.
+type openElement struct {
+	atom    atom.Atom
+	written bool // false for unwrapped elements, whose end tag is dropped too
+}
+
+// pttColors maps PTT's ANSI color classes (f1-f6, bright with hl) to CSS
+// colors. Black, white (f0, f7) and backgrounds (b0-b7) are dropped: PTT
+// renders on black while most feed readers use a light background.
+var pttColors = map[string][2]string{
+	"f1": {"#aa0000", "#ff5555"},
+	"f2": {"#00aa00", "#55ff55"},
+	"f3": {"#aa5500", "#ffff55"},
+	"f4": {"#0000aa", "#5555ff"},
+	"f5": {"#aa00aa", "#ff55ff"},
+	"f6": {"#00aaaa", "#55ffff"},
+}
+
+// HTML returns fragment with only allowlisted elements and attributes.
+// Relative links and image sources are resolved against base; links and
+// images with other schemes than http(s) (and mailto for links) are dropped.
+// Embedded iframes become plain links to their source.
+func HTML(fragment string, base *url.URL) string {
+	var out strings.Builder
+	var open []openElement
+	skip := 0 // 位於 dropped 元素內的深度
+
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.TextToken:
+			if skip == 0 {
+				out.WriteString(html.EscapeString(tok.Data))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if tok.DataAtom == atom.Iframe && skip == 0 {
+				writeEmbedLink(&out, tok, base)
+			}
+			if dropped[tok.DataAtom] {
+				if tt == html.StartTagToken && !voids[tok.DataAtom] {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			attrs, ok := element(tok, base)
+			if ok {
+				out.WriteString("<" + tok.Data)
+				for _, a := range attrs {
+					out.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+				}
+				out.WriteString(">")
+			}
+			if !voids[tok.DataAtom] && tt == html.StartTagToken {
+				open = append(open, openElement{atom: tok.DataAtom, written: ok})
+			}
+
+		case html.EndTagToken:
+			if dropped[tok.DataAtom] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			// 關閉到對應的開啟元素為止，沒有對應者則忽略
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i].atom == tok.DataAtom {
+					closeElements(&out, open[i:])
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+	closeElements(&out, open)
+	return out.String()
+}
+
+// RewriteImages returns fragment, an output of HTML, with the src of every
+// image replaced by rewrite(src). The rest of the markup is copied as is.
+func RewriteImages(fragment string, rewrite func(src string) string) string {
+	var out strings.Builder
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out.String()
+		}
+		raw := string(z.Raw())
+		tok := z.Token()
+		if (tt != html.StartTagToken && tt != html.SelfClosingTagToken) || tok.DataAtom != atom.Img {
+			out.WriteString(raw)
+			continue
+		}
+		out.WriteString("<" + tok.Data)
+		for _, a := range tok.Attr {
+			if a.Key == "src" {
+				a.Val = rewrite(a.Val)
+			}
+			out.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+		}
+		out.WriteString(">")
+	}
+}
+
+// closeElements writes the end tags of the written elements of open,
+// innermost first.
+func closeElements(out *strings.Builder, open []openElement) {
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i].written {
+			out.WriteString("</" + open[i].atom.String() + ">")
+		}
+	}
+}
+
+// element returns the attributes to keep on tok, or false when the tag
+// itself is dropped.
+func element(tok html.Token, base *url.URL) ([]html.Attribute, bool) {
+	names, ok := allowed[tok.DataAtom]
+	if !ok {
+		return nil, false
+	}
+	var attrs []html.Attribute
+	for _, a := range tok.Attr {
+		if !contains(names, a.Key) {
+			continue
+		}
+		switch a.Key {
+		case "href":
+			href, ok := resolve(a.Val, base, "http", "https", "mailto")
+			if !ok {
+				continue
+			}
+			a.Val = href
+		case "src":
+			src, ok := resolve(a.Val, base, "http", "https")
+			if !ok {
+				return nil, false // 沒有可用來源的圖片整個略過
+			}
+			a.Val = src
+		}
+		attrs = append(attrs, a)
+	}
+
+	switch tok.DataAtom {
+	case atom.A:
+		attrs = append(attrs, html.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"})
+	case atom.Img:
+		if !hasAttr(attrs, "src") {
+			return nil, false
+		}
+	case atom.Span:
+		style := pttStyle(attrValue(tok, "class"))
+		if style == "" {
+			return nil, false
+		}
+		attrs = append(attrs, html.Attribute{Key: "style", Val: style})
+	}
+	return attrs, true
+}
+
+// writeEmbedLink replaces an iframe (YouTube and similar embeds) with a link
+// to its source.
+func writeEmbedLink(out *strings.Builder, tok html.Token, base *url.URL) {
+	src, ok := resolve(attrValue(tok, "src"), base, "http", "https")
+	if !ok {
+		return
+	}
+	escaped := html.EscapeString(src)
+	out.WriteString(`<a href="` + escaped + `" rel="nofollow noopener noreferrer">` + escaped + `</a>`)
+}
+
+// pttStyle converts the classes of a PTT color span, e.g. "f3 hl", to an
+// inline style, or "" when nothing worth keeping is set.
+func pttStyle(class string) string {
+	fields := strings.Fields(class)
+	bright := 0
+	if contains(fields, "hl") {
+		bright = 1
+	}
+	for _, f := range fields {
+		if c, ok := pttColors[f]; ok {
+			return "color: " + c[bright]
+		}
+	}
+	return ""
+}
+
+// resolve returns raw as an absolute URL with one of schemes.
+func resolve(raw string, base *url.URL, schemes ...string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if !contains(schemes, strings.ToLower(u.Scheme)) || (u.Scheme != "mailto" && u.Host == "") {
+		return "", false
+	}
+	return u.String(), true
+}
+
+func attrValue(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(attrs []html.Attribute, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
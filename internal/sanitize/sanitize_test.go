@@ -0,0 +1,59 @@
+package sanitize
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	base, _ := url.Parse("https://www.ptt.cc/bbs/C_Chat/M.1769070000.A.4F4.html")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain text", "推薦這部<br>好看", "推薦這部<br>好看"},
+		{"text escaped", "a &lt; b &amp; c", "a &lt; b &amp; c"},
+		{"script dropped", `前<script>alert("x")</script>後`, "前後"},
+		{"imgur embed", `<div class="richcontent"><blockquote class="imgur-embed-pub" data-id="abc"><a href="//imgur.com/abc">圖</a></blockquote><script async src="//s.imgur.com/min/embed.js"></script></div>`,
+			`<div><blockquote><a href="https://imgur.com/abc" rel="nofollow noopener noreferrer">圖</a></blockquote></div>`},
+		{"iframe becomes link", `<iframe src="https://www.youtube.com/embed/xyz" allowfullscreen>fallback</iframe>`,
+			`<a href="https://www.youtube.com/embed/xyz" rel="nofollow noopener noreferrer">https://www.youtube.com/embed/xyz</a>`},
+		{"ptt color span", `<span class="f2">※ 編輯: user</span>`, `<span style="color: #00aa00">※ 編輯: user</span>`},
+		{"bright color", `<span class="f3 hl push-userid">user</span>`, `<span style="color: #ffff55">user</span>`},
+		{"default color stripped", `<span class="f7 b0">text</span>`, "text"},
+		{"stripped span keeps outer open", `<span class="f1">a<span class="article-meta-tag">b</span>c</span>`,
+			`<span style="color: #aa0000">abc</span>`},
+		{"relative link", `<a href="/bbs/C_Chat/index.html" class="board" target="_blank">看板</a>`,
+			`<a href="https://www.ptt.cc/bbs/C_Chat/index.html" rel="nofollow noopener noreferrer">看板</a>`},
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"image kept", `<img src="https://i.imgur.com/abc.jpg" alt="圖" onerror="alert(1)">`, `<img src="https://i.imgur.com/abc.jpg" alt="圖">`},
+		{"data image dropped", `<img src="data:image/png;base64,AAAA">`, ""},
+		{"event handlers dropped", `<div onclick="x()" style="position:fixed">內文</div>`, "<div>內文</div>"},
+		{"unknown tag unwrapped", `<marquee>跑馬燈</marquee>`, "跑馬燈"},
+		{"unclosed tags closed", `<b>粗<i>斜`, "<b>粗<i>斜</i></b>"},
+		{"stray end tag", `文</div>字`, "文字"},
+		{"attribute escaped", `<a href="https://example.com/?a=1&b=&quot;2&quot;">x</a>`,
+			`<a href="https://example.com/?a=1&amp;b=&#34;2&#34;" rel="nofollow noopener noreferrer">x</a>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.input, base); got != tt.expected {
+				t.Errorf("HTML() =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRewriteImages(t *testing.T) {
+	fragment := `<p>圖 <a href="https://imgur.com/abc" rel="nofollow noopener noreferrer">https://imgur.com/abc</a><img src="https://i.imgur.com/abc.jpg" alt="a&amp;b"></p>`
+	got := RewriteImages(fragment, func(src string) string {
+		return "https://feed.example.com/img?url=" + url.QueryEscape(src) + "&sig=x"
+	})
+	want := `<p>圖 <a href="https://imgur.com/abc" rel="nofollow noopener noreferrer">https://imgur.com/abc</a>` +
+		`<img src="https://feed.example.com/img?url=https%3A%2F%2Fi.imgur.com%2Fabc.jpg&amp;sig=x" alt="a&amp;b"></p>`
+	if got != want {
+		t.Errorf("RewriteImages() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,270 @@
+// Package savedfeed stores named feed definitions: a feed route with its
+// parameters, filters and format, or several of them merged into one
+// composite feed, served under a short ID at /f/{id}. Readers subscribe to
+// the short URL, so a feed can be changed on the server without
+// resubscribing.
+package savedfeed
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Errors returned by Store.
+var (
+	ErrNotFound = errors.New("saved feed not found")
+	ErrExists   = errors.New("saved feed already exists")
+	ErrReadOnly = errors.New("saved feed is defined in the config file and cannot be changed through the API")
+	ErrNoFile   = errors.New("saved feeds are read-only, set saved_feeds.file")
+)
+
+// idPattern matches feed IDs; they appear in URLs as /f/{id}.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Feed is one saved feed definition. It has either Source or, for a
+// composite feed, Sources.
+type Feed struct {
+	ID      string            `json:"id" yaml:"id"`
+	Title   string            `json:"title,omitempty" yaml:"title"`     // 覆寫 feed 標題，空白則沿用來源標題
+	Source  string            `json:"source,omitempty" yaml:"source"`   // feed 路由，例如 /ptt/trending
+	Params  map[string]string `json:"params,omitempty" yaml:"params"`   // 來源參數與篩選參數
+	Format  string            `json:"format,omitempty" yaml:"format"`   // rss、atom 或 json，空白為 rss
+	Sources []Source          `json:"sources,omitempty" yaml:"sources"` // 組合 feed 的各個來源
+	Limit   int               `json:"limit,omitempty" yaml:"limit"`     // 組合 feed 合併後最多幾則，0 為預設值
+
+	// ReadOnly marks feeds from the config file.
+	ReadOnly bool `json:"read_only,omitempty" yaml:"-"`
+}
+
+// Source is one query of a composite feed.
+type Source struct {
+	Source string            `json:"source" yaml:"source"`
+	Params map[string]string `json:"params,omitempty" yaml:"params"`
+}
+
+// Composite reports whether f merges several sources.
+func (f Feed) Composite() bool {
+	return len(f.Sources) > 0
+}
+
+// Query returns the source query parameters of f, including format.
+func (f Feed) Query() url.Values {
+	return query(f.Params, f.Format)
+}
+
+// Query returns the query parameters of s in a feed of format.
+func (s Source) Query(format string) url.Values {
+	return query(s.Params, format)
+}
+
+func query(params map[string]string, format string) url.Values {
+	q := make(url.Values, len(params)+1)
+	for name, value := range params {
+		q.Set(name, value)
+	}
+	if format != "" {
+		q.Set("format", format)
+	}
+	return q
+}
+
+// CheckID reports whether id is a valid feed ID.
+func CheckID(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("id %q must be 1 to 32 letters, digits, _ or -", id)
+	}
+	return nil
+}
+
+// NewID returns a random 8-character ID.
+func NewID() string {
+	const alphabet = "abcdefghijkmnpqrstuvwxyz23456789" // 去除易混淆的 l、o、0、1
+	b := make([]byte, 8)
+	rand.Read(b)
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b)
+}
+
+type file struct {
+	Feeds []Feed `json:"feeds"`
+}
+
+// Store holds the saved feeds of the config file, which are read-only, and
+// of a JSON file written by the admin API. It is safe for concurrent use.
+type Store struct {
+	path  string
+	fixed map[string]Feed
+
+	mu    sync.RWMutex
+	feeds map[string]Feed
+}
+
+// Open loads the feeds in path (which may not exist yet) on top of fixed,
+// the feeds from the config file. With an empty path only fixed is served
+// and changes are refused with ErrNoFile.
+func Open(path string, fixed []Feed) (*Store, error) {
+	s := &Store{path: path, fixed: make(map[string]Feed), feeds: make(map[string]Feed)}
+	for _, f := range fixed {
+		f.ReadOnly = true
+		s.fixed[f.ID] = f
+	}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved file
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, f := range saved.Feeds {
+		f.ReadOnly = false
+		s.feeds[f.ID] = f
+	}
+	return s, nil
+}
+
+// Path returns the file the store writes to.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get returns the feed with id.
+func (s *Store) Get(id string) (Feed, bool) {
+	if f, ok := s.fixed[id]; ok {
+		return f, true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.feeds[id]
+	return f, ok
+}
+
+// List returns every feed sorted by ID.
+func (s *Store) List() []Feed {
+	s.mu.RLock()
+	feeds := make([]Feed, 0, len(s.fixed)+len(s.feeds))
+	for id, f := range s.feeds {
+		if _, ok := s.fixed[id]; !ok {
+			feeds = append(feeds, f)
+		}
+	}
+	s.mu.RUnlock()
+	for _, f := range s.fixed {
+		feeds = append(feeds, f)
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].ID < feeds[j].ID })
+	return feeds
+}
+
+// Create adds f and writes the file, or returns ErrExists.
+func (s *Store) Create(f Feed) error {
+	_, err := s.put(f, false)
+	return err
+}
+
+// Put adds or replaces f and writes the file. It reports whether f is new.
+func (s *Store) Put(f Feed) (created bool, err error) {
+	return s.put(f, true)
+}
+
+func (s *Store) put(f Feed, replace bool) (created bool, err error) {
+	if err := CheckID(f.ID); err != nil {
+		return false, err
+	}
+	if _, ok := s.fixed[f.ID]; ok {
+		if !replace {
+			return false, ErrExists
+		}
+		return false, ErrReadOnly
+	}
+	if s.path == "" {
+		return false, ErrNoFile
+	}
+	f.ReadOnly = false
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.feeds[f.ID]
+	if existed && !replace {
+		return false, ErrExists
+	}
+	s.feeds[f.ID] = f
+	if err := s.save(); err != nil {
+		if existed {
+			s.feeds[f.ID] = old
+		} else {
+			delete(s.feeds, f.ID)
+		}
+		return false, err
+	}
+	return !existed, nil
+}
+
+// Delete removes the feed with id and writes the file.
+func (s *Store) Delete(id string) error {
+	if _, ok := s.fixed[id]; ok {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.feeds[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.feeds, id)
+	if err := s.save(); err != nil {
+		s.feeds[id] = old
+		return err
+	}
+	return nil
+}
+
+// save writes the feeds to s.path. Must be called with s.mu held.
+func (s *Store) save() error {
+	saved := file{Feeds: make([]Feed, 0, len(s.feeds))}
+	for _, f := range s.feeds {
+		saved.Feeds = append(saved.Feeds, f)
+	}
+	sort.Slice(saved.Feeds, func(i, j int) bool { return saved.Feeds[i].ID < saved.Feeds[j].ID })
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// 先寫暫存檔再改名，當機時不會留下寫到一半的檔案
+	tmp, err := os.CreateTemp(dir, ".feeds-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
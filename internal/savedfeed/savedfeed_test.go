@@ -0,0 +1,85 @@
+package savedfeed
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "feeds.json")
+	fixed := []Feed{{ID: "cchat", Source: "/ptt/trending", Params: map[string]string{"board": "C_Chat"}}}
+	s, err := Open(path, fixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hot := Feed{ID: "hot", Source: "/plurk/top", Params: map[string]string{"qType": "hot"}, Format: "atom"}
+	if created, err := s.Put(hot); err != nil || !created {
+		t.Fatalf("Put = %v, %v; want created", created, err)
+	}
+	hot.Title = "噗浪熱門"
+	if created, err := s.Put(hot); err != nil || created {
+		t.Fatalf("Put = %v, %v; want replaced", created, err)
+	}
+	if err := s.Create(Feed{ID: "hot", Source: "/plurk/top"}); !errors.Is(err, ErrExists) {
+		t.Errorf("Create existing: err = %v, want ErrExists", err)
+	}
+	if _, err := s.Put(Feed{ID: "cchat", Source: "/plurk/top"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Put over config feed: err = %v, want ErrReadOnly", err)
+	}
+	if _, err := s.Put(Feed{ID: "../x", Source: "/plurk/top"}); err == nil {
+		t.Error("Put accepted an invalid ID")
+	}
+	if err := s.Delete("cchat"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete config feed: err = %v, want ErrReadOnly", err)
+	}
+	if err := s.Delete("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete missing: err = %v, want ErrNotFound", err)
+	}
+
+	// 重新開啟後保留 API 寫入的 feed
+	reopened, err := Open(path, fixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := reopened.Get("hot")
+	if !ok || got.Title != "噗浪熱門" || got.Query().Get("format") != "atom" || got.Query().Get("qType") != "hot" {
+		t.Fatalf("Get = %+v, %v", got, ok)
+	}
+	list := reopened.List()
+	if len(list) != 2 || list[0].ID != "cchat" || !list[0].ReadOnly || list[1].ReadOnly {
+		t.Errorf("List = %+v", list)
+	}
+
+	if err := reopened.Delete("hot"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("hot"); ok {
+		t.Error("deleted feed still served")
+	}
+}
+
+func TestStoreWithoutFile(t *testing.T) {
+	s, err := Open("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(Feed{ID: "hot", Source: "/plurk/top"}); !errors.Is(err, ErrNoFile) {
+		t.Errorf("err = %v, want ErrNoFile", err)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := NewID()
+		if err := CheckID(id); err != nil || len(id) != 8 {
+			t.Fatalf("NewID() = %q: %v", id, err)
+		}
+		if seen[id] {
+			t.Fatalf("NewID() repeated %q", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpansAndTraceContextForwarding(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(original)
+
+	var traceparent string
+	predict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"probability":0.5}`))
+	}))
+	defer predict.Close()
+
+	h := Middleware("/ptt/trending", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Start(r.Context(), "predict")
+		defer span.End()
+		req, _ := http.NewRequestWithContext(ctx, "POST", predict.URL+"/predict", nil)
+		resp, err := (&http.Client{Transport: InternalTransport(nil)}).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}))
+
+	// 呼叫端帶入的 trace 應該延續下去
+	const callerTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/ptt/trending?board=C_Chat", nil)
+	req.Header.Set("traceparent", "00-"+callerTrace+"-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(traceparent, callerTrace) {
+		t.Errorf("predict service traceparent = %q, want trace %s", traceparent, callerTrace)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want client, predict and server", len(spans))
+	}
+	client, parent, server := spans[0], spans[1], spans[2]
+	if server.Name() != "GET /ptt/trending" || parent.Name() != "predict" {
+		t.Errorf("span names = %q, %q, %q", client.Name(), parent.Name(), server.Name())
+	}
+	if client.Parent().SpanID() != parent.SpanContext().SpanID() || parent.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("spans are not nested client < predict < server")
+	}
+	for _, span := range spans {
+		if span.SpanContext().TraceID().String() != callerTrace {
+			t.Errorf("span %s has trace %s, want %s", span.Name(), span.SpanContext().TraceID(), callerTrace)
+		}
+	}
+	if !strings.Contains(traceparent, client.SpanContext().SpanID().String()) {
+		t.Errorf("traceparent %q does not name the client span", traceparent)
+	}
+}
+
+func TestTransportKeepsTraceContextInternal(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	var headers []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get("traceparent"))
+	}))
+	defer upstream.Close()
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "feed")
+	defer span.End()
+	for _, transport := range []http.RoundTripper{Transport(nil), InternalTransport(nil)} {
+		req, _ := http.NewRequestWithContext(ctx, "GET", upstream.URL, nil)
+		resp, err := (&http.Client{Transport: transport}).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// ptt.cc、plurk.com 與圖片主機不應收到 traceparent
+	if headers[0] != "" || headers[1] == "" {
+		t.Errorf("traceparent = %q (Transport), %q (InternalTransport)", headers[0], headers[1])
+	}
+}
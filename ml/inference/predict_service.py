@@ -36,6 +36,14 @@ app = FastAPI(
 # Global model instance
 model: Optional[xgb.XGBClassifier] = None
 
+# Board-specific models requested by name (e.g. viral_predictor_steam.json)
+named_models: dict[str, xgb.XGBClassifier] = {}
+
+MODEL_DIRS = [
+    Path(__file__).parent.parent / "models",
+    Path("/app/models"),  # Docker path
+]
+
 
 def window_aliases(metric: str) -> AliasChoices:
     """Accept current generic fields and legacy time-window-specific fields."""
@@ -62,7 +70,11 @@ class PredictRequest(BaseModel):
     day_of_week: int
     title_length: int
     has_image: bool
+    # Optional: number of image links; estimated from has_image if omitted
+    image_count: Optional[int] = None
     tag_type: str
+    # Optional: model file name for board-specific models (default model if omitted)
+    model: Optional[str] = None
 
 
 class PredictResponse(BaseModel):
@@ -113,29 +125,69 @@ def load_model():
 
     for model_path in model_paths:
         if model_path.exists():
-            estimator = xgb.XGBClassifier()
-            # XGBoost 2.0+ does not set _estimator_type until after fit; loading a saved
-            # model requires priming the attribute to avoid TypeError during load_model.
-            if not getattr(estimator, "_estimator_type", None):
-                estimator._estimator_type = "classifier"
-
-            try:
-                estimator.load_model(str(model_path))
-            except TypeError:
-                estimator._estimator_type = "classifier"
-                estimator.load_model(str(model_path))
-
-            # Manually restore minimal sklearn attributes required for predict_proba
-            estimator.__dict__["n_classes_"] = 2
-            estimator.__dict__["classes_"] = np.array([0, 1])
-
-            model = estimator
+            model = load_estimator(model_path)
             print(f"Model loaded from: {model_path} (TIME_WINDOW={TIME_WINDOW}min)")
             return
 
     raise FileNotFoundError(f"Model file not found in any of: {model_paths}")
 
 
+def load_estimator(model_path: Path) -> xgb.XGBClassifier:
+    """Load a saved XGBoost classifier ready for predict_proba"""
+    estimator = xgb.XGBClassifier()
+    # XGBoost 2.0+ does not set _estimator_type until after fit; loading a saved
+    # model requires priming the attribute to avoid TypeError during load_model.
+    if not getattr(estimator, "_estimator_type", None):
+        estimator._estimator_type = "classifier"
+
+    try:
+        estimator.load_model(str(model_path))
+    except TypeError:
+        estimator._estimator_type = "classifier"
+        estimator.load_model(str(model_path))
+
+    # Manually restore minimal sklearn attributes required for predict_proba
+    estimator.__dict__["n_classes_"] = 2
+    estimator.__dict__["classes_"] = np.array([0, 1])
+    return estimator
+
+
+def get_model(name: Optional[str]) -> xgb.XGBClassifier:
+    """Return the default model, or a board-specific model file by name"""
+    if not name:
+        if model is None:
+            raise HTTPException(status_code=503, detail="Model not loaded")
+        return model
+
+    if name in named_models:
+        return named_models[name]
+
+    # Only plain file names inside the models directory are allowed
+    if Path(name).name != name or not name.endswith(".json"):
+        raise HTTPException(status_code=400, detail=f"Invalid model name: {name}")
+
+    for model_dir in MODEL_DIRS:
+        model_path = model_dir / name
+        if model_path.exists():
+            named_models[name] = load_estimator(model_path)
+            print(f"Model loaded from: {model_path} (requested as {name})")
+            return named_models[name]
+
+    raise HTTPException(status_code=404, detail=f"Model not found: {name}")
+
+
+def model_features(estimator: xgb.XGBClassifier, features: list) -> list:
+    """Trim the feature vector to the features the model was trained on.
+
+    New features are appended to FEATURE_NAMES, so models trained before
+    them (e.g. without image_count) keep working on the leading features.
+    """
+    try:
+        return features[: estimator.get_booster().num_features()]
+    except (AttributeError, TypeError, xgb.core.XGBoostError):
+        return features
+
+
 def request_to_features(req: PredictRequest) -> list:
     """Convert prediction request to feature vector"""
     # Calculate derived features
@@ -169,6 +221,7 @@ def request_to_features(req: PredictRequest) -> list:
         "has_tag": bool(req.tag_type),
         "has_image": req.has_image,
         "content_length": 0,  # Not provided in request, use default
+        "image_count": req.image_count if req.image_count is not None else int(req.has_image),
     }
 
     # Convert to vector in FEATURE_NAMES order
@@ -210,11 +263,10 @@ async def health(response: Response):
 @app.post("/predict", response_model=PredictResponse)
 async def predict(req: PredictRequest):
     """Predict viral probability for a single article"""
-    if model is None:
-        raise HTTPException(status_code=503, detail="Model not loaded")
+    estimator = get_model(req.model)
 
-    features = request_to_features(req)
-    prob = model.predict_proba([features])[0][1]
+    features = model_features(estimator, request_to_features(req))
+    prob = estimator.predict_proba([features])[0][1]
 
     return PredictResponse(probability=float(prob))
 
@@ -227,8 +279,9 @@ async def predict_batch(req: BatchPredictRequest):
 
     predictions = []
     for article in req.articles:
-        features = request_to_features(article)
-        prob = model.predict_proba([features])[0][1]
+        estimator = get_model(article.model)
+        features = model_features(estimator, request_to_features(article))
+        prob = estimator.predict_proba([features])[0][1]
         predictions.append(PredictResponse(probability=float(prob)))
 
     return BatchPredictResponse(predictions=predictions)
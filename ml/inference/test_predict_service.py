@@ -34,6 +34,28 @@ class TestPredictEndpoint:
         assert "probability" in data
         assert 0.0 <= data["probability"] <= 1.0
 
+    def test_predict_accepts_image_count(self, client):
+        """image_count is optional; models trained without it ignore it"""
+        request_data = {
+            "board": "C_Chat",
+            "title": "[閒聊] 今天的動畫好好看",
+            "post_time": "2026-01-22T20:00:00",
+            "comments_window": 10,
+            "push_window": 8,
+            "boo_window": 1,
+            "hour_of_day": 20,
+            "day_of_week": 3,
+            "title_length": 15,
+            "has_image": True,
+            "image_count": 3,
+            "tag_type": "閒聊",
+        }
+
+        response = client.post("/predict", json=request_data)
+
+        assert response.status_code == 200
+        assert 0.0 <= response.json()["probability"] <= 1.0
+
     def test_predict_high_engagement_returns_high_prob(self, client):
         """Article with high early engagement should have higher probability"""
         high_engagement = {
@@ -110,6 +132,48 @@ class TestPredictEndpoint:
         assert response.status_code == 200
         assert "probability" in response.json()
 
+    def test_predict_with_named_model(self, client):
+        """POST /predict should use a board-specific model file when requested."""
+        request_data = {
+            "board": "Steam",
+            "title": "[情報] 特賣",
+            "post_time": "2026-01-22T20:00:00",
+            "comments_window": 10,
+            "push_window": 8,
+            "boo_window": 1,
+            "hour_of_day": 20,
+            "day_of_week": 3,
+            "title_length": 15,
+            "has_image": False,
+            "tag_type": "情報",
+            "model": "viral_predictor_final.json",
+        }
+
+        response = client.post("/predict", json=request_data)
+
+        assert response.status_code == 200
+        assert 0.0 <= response.json()["probability"] <= 1.0
+
+    def test_predict_rejects_model_path(self, client):
+        """Model names with directories should be rejected."""
+        request_data = {
+            "board": "Steam",
+            "title": "Test",
+            "post_time": "2026-01-22T20:00:00",
+            "comments_window": 1,
+            "push_window": 1,
+            "boo_window": 0,
+            "hour_of_day": 20,
+            "day_of_week": 3,
+            "title_length": 4,
+            "has_image": False,
+            "tag_type": "",
+            "model": "../models/viral_predictor_final.json",
+        }
+
+        response = client.post("/predict", json=request_data)
+        assert response.status_code == 400
+
 
 class TestHealthEndpoint:
     """Test /health endpoint"""
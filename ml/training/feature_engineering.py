@@ -147,6 +147,22 @@ def extract_time_features(post_time: str) -> dict:
     }
 
 
+# Image links recognized by the Go service (internal/media): imgur pages and
+# direct images (albums and galleries excluded), Twitter and Plurk images.
+IMAGE_PATTERN = re.compile(
+    r"(?:i\.|m\.|www\.)?imgur\.com/(?!a/|gallery/)([A-Za-z0-9]{5,8})\b"
+    r"|(pbs\.twimg\.com/media/[^\s\"'<>]+)"
+    r"|images\.plurk\.com/(?:mx_)?([^\s\"'<>/]+\.(?:jpe?g|png|gif|webp))",
+    re.IGNORECASE,
+)
+
+
+def count_images(text: str) -> int:
+    """Count distinct image links; an imgur page and its direct link count once."""
+    # Exactly one group matches: the imgur ID, twimg path or Plurk file name
+    return len({"".join(match.groups("")) for match in IMAGE_PATTERN.finditer(text)})
+
+
 def extract_text_features(title: str, content: str) -> dict:
     """Extract text-based features from title and content."""
     # Check for [標籤] pattern
@@ -154,14 +170,15 @@ def extract_text_features(title: str, content: str) -> dict:
     has_tag = tag_match is not None
     tag_type = tag_match.group(1) if tag_match else ""
 
-    # Check for imgur link
-    has_image = "imgur.com" in content or "imgur.com" in title
+    # Count image links (imgur, pbs.twimg, Plurk)
+    image_count = count_images(content + "\n" + title)
 
     return {
         "title_length": len(title),
         "has_tag": has_tag,
         "tag_type": tag_type,
-        "has_image": has_image,
+        "has_image": image_count > 0,
+        "image_count": image_count,
         "content_length": len(content),
     }
 
@@ -255,6 +272,8 @@ def get_feature_names(time_window: int = 15) -> list[str]:
         "has_tag",
         "has_image",
         "content_length",
+        # Appended last: models trained before it use only the first 15 features
+        "image_count",
     ]
 
 
@@ -200,6 +200,22 @@ class TestTextFeatures:
         assert features_with["has_image"] == True
         assert features_without["has_image"] == False
 
+    def test_counts_distinct_images(self):
+        """Should count imgur, twimg and Plurk images, each image once."""
+        from feature_engineering import extract_text_features
+
+        content = (
+            "https://imgur.com/abc123 https://i.imgur.com/abc123.jpg\n"
+            "https://i.imgur.com/XyZ9876.png https://imgur.com/a/album1\n"
+            "https://pbs.twimg.com/media/GabcDEF?format=jpg&name=large\n"
+            "https://images.plurk.com/mx_5Nq2bGxYfZkZ.jpg https://images.plurk.com/5Nq2bGxYfZkZ.jpg"
+        )
+
+        features = extract_text_features("title", content)
+
+        assert features["image_count"] == 4
+        assert extract_text_features("title", "純文字內容")["image_count"] == 0
+
     def test_calculates_content_length(self):
         """Should calculate content length."""
         from feature_engineering import extract_text_features
@@ -255,6 +271,7 @@ class TestExtractAllFeatures:
         assert "has_tag" in features
         assert "tag_type" in features
         assert "has_image" in features
+        assert "image_count" in features
         assert "content_length" in features
 
     def test_returns_feature_vector_for_model(self):
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Harrison-Dev/go_feed_tool/internal/handler"
+	"github.com/stretchr/testify/assert"
+)
+
+// 重新產生 golden 檔: go test ./tests -run TestGoldenFeeds -update
+var update = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// goldenNow 固定在 fixtures 的時間點 (2026-01-22 20:55 台北)，讓潛在爆文的時窗可重現
+var goldenNow = time.Date(2026, 1, 22, 20, 55, 0, 0, time.FixedZone("CST", 8*60*60))
+
+func TestGoldenFeeds(t *testing.T) {
+	originalNow := handler.Now
+	handler.Now = func() time.Time { return goldenNow }
+	defer func() { handler.Now = originalNow }()
+
+	// 假預測服務: 機率 = 時窗內留言數 / 100
+	predict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req handler.PredictRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(handler.PredictResponse{
+			Probability: min(float64(req.CommentsWindow)/100, 0.99),
+		})
+	}))
+	defer predict.Close()
+	originalURL := handler.PredictServiceURL
+	handler.PredictServiceURL = predict.URL
+	defer func() { handler.PredictServiceURL = originalURL }()
+
+	router := setupRouter()
+
+	endpoints := []struct {
+		name string
+		path string
+	}{
+		{"ptt_search", "/ptt/search?board=C_Chat&keyword=閒聊"},
+		{"ptt_trending_all", "/ptt/trending?board=C_Chat&mode=all"},
+		{"ptt_trending_controversial", "/ptt/trending?board=C_Chat&mode=controversial"},
+		// 篩選在 limit 之前: 排除已爆文後仍回傳 1 篇潛在爆文
+		{"ptt_trending_filtered", "/ptt/trending?board=C_Chat&mode=all&limit=1&exclude_author=kirimaru"},
+		{"plurk_search", "/plurk/search?keyword=台灣"},
+		{"plurk_top", "/plurk/top?qType=topResponded"},
+	}
+	formats := []string{"rss", "atom", "json"}
+
+	for _, endpoint := range endpoints {
+		for _, format := range formats {
+			name := endpoint.name + "." + format
+			t.Run(name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", endpoint.path+"&format="+format, nil)
+				router.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusOK, w.Code)
+
+				golden := filepath.Join("testdata", "golden", name)
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(golden, w.Body.Bytes(), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("missing golden file (run with -update): %v", err)
+				}
+				assert.Equal(t, string(want), w.Body.String())
+			})
+		}
+	}
+}
+
+func TestFeedFormat(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		format         string
+		expectedStatus int
+		contentType    string
+	}{
+		{"", 200, "application/rss+xml; charset=utf-8"},
+		{"atom", 200, "application/atom+xml; charset=utf-8"},
+		{"json", 200, "application/feed+json; charset=utf-8"},
+		{"xml", 400, "application/json; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/plurk/top?qType=hot&format="+tt.format, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+		})
+	}
+}
@@ -1,47 +1,56 @@
 package tests
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/Harrison-Dev/go_feed_tool/internal/config"
+	"github.com/Harrison-Dev/go_feed_tool/internal/fakeupstream"
 	"github.com/Harrison-Dev/go_feed_tool/internal/handler"
+	"github.com/Harrison-Dev/go_feed_tool/internal/savedfeed"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
+// 所有測試都打本機的假 PTT / Plurk 伺服器 (錄製的 fixtures)，不需要網路
+var (
+	fakePTT   *fakeupstream.Server
+	fakePlurk *fakeupstream.Server
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	fakePTT = fakeupstream.NewPTT()
+	fakePlurk = fakeupstream.NewPlurk()
+
+	cfg := config.Default()
+	cfg.Upstream.PttBaseURL = fakePTT.URL
+	cfg.Upstream.PlurkBaseURL = fakePlurk.URL
+	// 所有請求都來自同一個 httptest 位址，頻率限制另有單元測試
+	cfg.RateLimit.PerMinute = 0
+	if err := handler.Configure(cfg); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	fakePTT.Close()
+	fakePlurk.Close()
+	os.Exit(code)
+}
+
+// setupRouter mounts the shared route table the same way cmd/server does.
 func setupRouter() *gin.Engine {
 	r := gin.Default()
-	r.GET("/ptt/search", func(c *gin.Context) {
-		parser := handler.NewPttParser(http.DefaultClient)
-		keyword := c.Query("keyword")
-		board := c.Query("board")
-		rss, err := parser.FetchArticles(board, keyword)
-		if err != nil {
-			c.String(http.StatusInternalServerError, err.Error())
-			return
-		}
-		c.String(http.StatusOK, rss)
-	})
-	r.GET("/plurk/search", func(c *gin.Context) {
-		keyword := c.Query("keyword")
-		rss, err := handler.ProcessPlurkSearch(keyword)
-		if err != nil {
-			c.String(http.StatusInternalServerError, err.Error())
-			return
-		}
-		c.String(http.StatusOK, rss)
-	})
-	r.GET("/plurk/top", func(c *gin.Context) {
-		qType := c.Query("qType")
-		rss, err := handler.ProcessPlurkTop(qType)
-		if err != nil {
-			c.String(http.StatusInternalServerError, err.Error())
-			return
-		}
-		c.String(http.StatusOK, rss)
-	})
+	for _, route := range handler.Routes {
+		r.Handle(route.HTTPMethod(), strings.ReplaceAll(route.Path, "{id}", ":id"), gin.WrapF(handler.Mount(route)))
+	}
 	return r
 }
 
@@ -82,17 +91,32 @@ func TestPTTSearch(t *testing.T) {
 				var rss RSS
 				err := xml.Unmarshal([]byte(response), &rss)
 				assert.NoError(t, err, "应该能够解析 XML")
-				assert.NotEmpty(t, rss.Channel.Items, "应该有搜索结果")
+				assert.Len(t, rss.Channel.Items, 2, "应该有搜索结果")
 
 				// 检查标题格式
 				assert.Contains(t, rss.Channel.Title, "PTT C_Chat Search")
+				assert.Equal(t, "[閒聊] 芙莉蓮 第二季 第3集 好好看", rss.Channel.Items[0].Title)
+				assert.Equal(t, "https://bbs.beptt.cc/C_Chat/M.1769083200.A.1C1.html", rss.Channel.Items[0].Link)
+				assert.NotContains(t, rss.Channel.Items[0].Description, "發信站")
+			},
+		},
+		{
+			name:           "十八禁看板",
+			board:          "Gossiping",
+			keyword:        "火鍋",
+			expectedStatus: 200,
+			checkResponse: func(t *testing.T, response string) {
+				var rss RSS
+				err := xml.Unmarshal([]byte(response), &rss)
+				assert.NoError(t, err, "应该能够解析 XML")
+				assert.Len(t, rss.Channel.Items, 1, "over18 cookie 应该通过年龄验证")
 			},
 		},
 		{
 			name:           "空看板测试",
 			board:          "",
 			keyword:        "test",
-			expectedStatus: 500,
+			expectedStatus: 400,
 			checkResponse: func(t *testing.T, response string) {
 				assert.Contains(t, response, "error")
 			},
@@ -135,12 +159,15 @@ func TestPlurkSearch(t *testing.T) {
 
 				// 检查标题格式
 				assert.Contains(t, rss.Channel.Title, "Plurk Search")
+				// 时间格式错误的噗会被略过
+				assert.Len(t, rss.Channel.Items, 2)
+				assert.Equal(t, "https://www.plurk.com/p/q3ks7h", rss.Channel.Items[0].Link)
 			},
 		},
 		{
 			name:           "空关键词测试",
 			keyword:        "",
-			expectedStatus: 500,
+			expectedStatus: 400,
 			checkResponse: func(t *testing.T, response string) {
 				assert.Contains(t, response, "error")
 			},
@@ -182,10 +209,22 @@ func TestPlurkTop(t *testing.T) {
 				assert.NotEmpty(t, rss.Channel.Items, "应该有搜索结果")
 			},
 		},
+		{
+			name:           "热门噗文测试",
+			qType:          "hot",
+			expectedStatus: 200,
+			checkResponse: func(t *testing.T, response string) {
+				var rss RSS
+				err := xml.Unmarshal([]byte(response), &rss)
+				assert.NoError(t, err, "应该能够解析 XML")
+				assert.Len(t, rss.Channel.Items, 1)
+				assert.Equal(t, "Catmom", rss.Channel.Items[0].Author)
+			},
+		},
 		{
 			name:           "无效类型测试",
 			qType:          "invalid",
-			expectedStatus: 500,
+			expectedStatus: 400,
 			checkResponse: func(t *testing.T, response string) {
 				assert.Contains(t, response, "error")
 			},
@@ -203,3 +242,211 @@ func TestPlurkTop(t *testing.T) {
 		})
 	}
 }
+
+func TestPTTTrending(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		checkResponse  func(*testing.T, string)
+	}{
+		{
+			name:           "已爆文",
+			query:          "board=C_Chat&mode=viral",
+			expectedStatus: 200,
+			checkResponse: func(t *testing.T, response string) {
+				var rss RSS
+				err := xml.Unmarshal([]byte(response), &rss)
+				assert.NoError(t, err, "应该能够解析 XML")
+				assert.Len(t, rss.Channel.Items, 1)
+				assert.Equal(t, "[🔥103推] [閒聊] 芙莉蓮 第二季 第3集 好好看", rss.Channel.Items[0].Title)
+			},
+		},
+		{
+			name:           "爭議文",
+			query:          "board=C_Chat&mode=controversial",
+			expectedStatus: 200,
+			checkResponse: func(t *testing.T, response string) {
+				var rss RSS
+				err := xml.Unmarshal([]byte(response), &rss)
+				assert.NoError(t, err, "应该能够解析 XML")
+				assert.Len(t, rss.Channel.Items, 1)
+				assert.Equal(t, "[💢60噓] [閒聊] 這季動畫其實普普吧", rss.Channel.Items[0].Title)
+			},
+		},
+		{
+			name:           "無效模式",
+			query:          "board=C_Chat&mode=hot",
+			expectedStatus: 400,
+			checkResponse: func(t *testing.T, response string) {
+				assert.Contains(t, response, `"param":"mode"`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/ptt/trending?"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			tt.checkResponse(t, w.Body.String())
+		})
+	}
+
+	// 置底公告不應該被抓取
+	for _, request := range fakePTT.Requests() {
+		assert.False(t, strings.Contains(request, "M.1500000000"), "pinned post fetched: %s", request)
+	}
+}
+
+// 篩選參數適用於所有 feed 路由
+func TestFeedFilters(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		name   string
+		url    string
+		titles []string
+	}{
+		{"排除關鍵字", "/ptt/search?board=C_Chat&keyword=閒聊&exclude=普普", []string{"[閒聊] 芙莉蓮 第二季 第3集 好好看"}},
+		{"推文數下限", "/ptt/search?board=C_Chat&keyword=閒聊&min_pushes=50", []string{"[閒聊] 芙莉蓮 第二季 第3集 好好看"}},
+		{"只保留標籤", "/ptt/search?board=C_Chat&keyword=閒聊&include_tag=情報", nil},
+		{"標題正規表示式", "/ptt/trending?board=C_Chat&mode=all&title_regex=" + url.QueryEscape("第\\d+集"), []string{"[🔥103推] [閒聊] 芙莉蓮 第二季 第3集 好好看"}},
+		{"噗浪內文", "/plurk/search?keyword=台灣&exclude=牛肉麵", []string{"台灣的冬天\n真的好濕冷 "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code, w.Body.String())
+
+			var rss RSS
+			assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &rss))
+			var titles []string
+			for _, item := range rss.Channel.Items {
+				titles = append(titles, item.Title)
+			}
+			assert.Equal(t, tt.titles, titles)
+		})
+	}
+}
+
+func TestSavedFeed(t *testing.T) {
+	cfg := config.Default()
+	cfg.Upstream.PttBaseURL = fakePTT.URL
+	cfg.Upstream.PlurkBaseURL = fakePlurk.URL
+	cfg.RateLimit.PerMinute = 0
+	cfg.SavedFeeds.Feeds = []savedfeed.Feed{{
+		ID:     "frieren",
+		Title:  "芙莉蓮討論",
+		Source: "/ptt/search",
+		Params: map[string]string{"board": "C_Chat", "keyword": "閒聊", "exclude": "普普"},
+	}}
+	if err := handler.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cfg.SavedFeeds.Feeds = nil
+		handler.Configure(cfg)
+	}()
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/f/frieren", nil))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var rss RSS
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &rss))
+	assert.Equal(t, "芙莉蓮討論", rss.Channel.Title)
+	if assert.Len(t, rss.Channel.Items, 1) {
+		assert.Equal(t, "[閒聊] 芙莉蓮 第二季 第3集 好好看", rss.Channel.Items[0].Title)
+	}
+
+	// 網址上的 format 與篩選參數覆寫定義
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/f/frieren?format=json&exclude=", nil))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/feed+json")
+	assert.Contains(t, w.Body.String(), "普普")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/f/missing", nil))
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestCompositeFeed(t *testing.T) {
+	cfg := config.Default()
+	cfg.Upstream.PttBaseURL = fakePTT.URL
+	cfg.Upstream.PlurkBaseURL = fakePlurk.URL
+	cfg.RateLimit.PerMinute = 0
+	cfg.SavedFeeds.Feeds = []savedfeed.Feed{{
+		ID:    "team",
+		Title: "追蹤清單",
+		Sources: []savedfeed.Source{
+			{Source: "/ptt/search", Params: map[string]string{"board": "C_Chat", "keyword": "閒聊"}},
+			{Source: "/ptt/trending", Params: map[string]string{"board": "C_Chat", "mode": "viral"}},
+			{Source: "/plurk/search", Params: map[string]string{"keyword": "台灣"}},
+			{Source: "/ptt/trending", Params: map[string]string{"board": "Steam", "mode": "all"}},
+		},
+	}, {
+		ID:      "top2",
+		Sources: []savedfeed.Source{{Source: "/plurk/search", Params: map[string]string{"keyword": "台灣"}}, {Source: "/ptt/trending", Params: map[string]string{"board": "C_Chat", "mode": "all"}}},
+		Limit:   2,
+	}, {
+		ID:      "down",
+		Sources: []savedfeed.Source{{Source: "/ptt/trending", Params: map[string]string{"board": "Steam", "mode": "all"}}},
+	}}
+	if err := handler.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cfg.SavedFeeds.Feeds = nil
+		handler.Configure(cfg)
+	}()
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/f/team", nil))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var rss RSS
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &rss))
+	assert.Equal(t, "追蹤清單", rss.Channel.Title)
+	// 依時間排序；兩個 PTT 來源都有的 103 推文章只出現一次，取自先列出的搜尋來源；
+	// 失敗的來源成為一則錯誤項目
+	var titles []string
+	for _, item := range rss.Channel.Items {
+		titles = append(titles, strings.TrimSpace(item.Title))
+	}
+	assert.Equal(t, []string{
+		"[錯誤] 來源 /ptt/trending 無法取得",
+		"[閒聊] 芙莉蓮 第二季 第3集 好好看",
+		"今天在台灣吃到超好吃的牛肉麵",
+		"[閒聊] 這季動畫其實普普吧",
+		"台灣的冬天\n真的好濕冷",
+	}, titles)
+	if len(rss.Channel.Items) > 0 {
+		assert.Equal(t, "https://www.ptt.cc/bbs/Steam/index.html", rss.Channel.Items[0].Link)
+		assert.Contains(t, rss.Channel.Items[0].Description, "404")
+	}
+
+	// limit 套用在合併之後
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/f/top2?format=json", nil))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var jsonFeed struct {
+		Items []struct {
+			Title string `json:"title"`
+		} `json:"items"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &jsonFeed))
+	assert.Len(t, jsonFeed.Items, 2)
+
+	// 所有來源都失敗時才回應錯誤
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/f/down", nil))
+	assert.Equal(t, 500, w.Code)
+}